@@ -0,0 +1,120 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"syscall"
+)
+
+func run(command string, arguments ...string) error {
+	_, _, err := runCommand(command, false, arguments...)
+	return err
+}
+
+func runWithOutput(command string, arguments ...string) (int, string, error) {
+	return runCommand(command, true, arguments...)
+}
+
+func runContext(ctx context.Context, command string, arguments ...string) error {
+	_, _, err := runCommandContext(ctx, command, false, arguments...)
+	return err
+}
+
+func runWithOutputContext(ctx context.Context, command string, arguments ...string) (int, string, error) {
+	return runCommandContext(ctx, command, true, arguments...)
+}
+
+// runShellContext runs script with the platform shell.
+func runShellContext(ctx context.Context, script string) error {
+	command, arguments := shellCommand(script)
+	return runContext(ctx, command, arguments...)
+}
+
+func runCommand(command string, readStdout bool, arguments ...string) (int, string, error) {
+	return runCommandContext(context.Background(), command, readStdout, arguments...)
+}
+
+// runCommandContext runs command like runCommand. If ctx is done first, the
+// command and its children are killed and the CommandError wraps ctx.Err().
+func runCommandContext(ctx context.Context, command string, readStdout bool, arguments ...string) (int, string, error) {
+	return commandRunner(ctx, command, readStdout, arguments...)
+}
+
+// commandRunner runs every command the package uses to control services.
+// Tests replace it to fake the service manager.
+var commandRunner = execCommand
+
+// execCommand is the commandRunner running command with os/exec.
+func execCommand(ctx context.Context, command string, readStdout bool, arguments ...string) (int, string, error) {
+	cmd := exec.Command(command, arguments...)
+
+	var stdout, stderr bytes.Buffer
+	if readStdout {
+		cmd.Stdout = &stdout
+	}
+	cmd.Stderr = &stderr
+	if ctx.Done() != nil {
+		// Only commands that can be canceled leave the foreground process
+		// group, others may still need the terminal.
+		setCommandProcessGroup(cmd)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return 0, "", &CommandError{Command: command, Args: arguments, Err: err}
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		killCommand(cmd)
+		// Do not wait for the output, a child that left the process group
+		// may keep the pipes open.
+		return 0, "", &CommandError{Command: command, Args: arguments, Err: ctx.Err()}
+	}
+	output := stdout.String()
+
+	// Zero exit status
+	// Darwin: launchctl can fail with a zero exit status,
+	// so check for emtpy stderr
+	if command == "launchctl" {
+		slurp := stderr.Bytes()
+		if len(slurp) > 0 && !bytes.HasSuffix(slurp, []byte("Operation now in progress\n")) {
+			return 0, "", &CommandError{Command: command, Args: arguments, Stderr: string(slurp)}
+		}
+	}
+
+	if err != nil {
+		// exitStatus is zero if an error occurred and there is no exit status.
+		exitStatus, _ := isExitError(err)
+		return exitStatus, output, &CommandError{
+			Command:  command,
+			Args:     arguments,
+			ExitCode: exitStatus,
+			Stdout:   output,
+			Stderr:   stderr.String(),
+			Err:      err,
+		}
+	}
+
+	return 0, output, nil
+}
+
+func isExitError(err error) (int, bool) {
+	if exiterr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
+			return status.ExitStatus(), true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,50 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"context"
+	"strings"
+)
+
+// fakeResult is the canned result of a command run by fakeCommandRunner.
+type fakeResult struct {
+	exitCode int
+	stdout   string
+}
+
+// fakeCommandRunner replaces commandRunner with one answering from results,
+// keyed by the command line. Unknown commands fail with exit code 127. It
+// returns the command lines run and a func restoring commandRunner.
+func fakeCommandRunner(results map[string]fakeResult) (calls *[]string, restore func()) {
+	return fakeCommandFunc(func(ctx context.Context, line string) (fakeResult, error) {
+		r, found := results[line]
+		if !found {
+			r.exitCode = 127
+		}
+		return r, nil
+	})
+}
+
+// fakeCommandFunc is fakeCommandRunner for fakes that keep state, answer
+// returns the result of each command line. An error is the Err of the
+// CommandError, as for a command that could not be run.
+func fakeCommandFunc(answer func(ctx context.Context, line string) (fakeResult, error)) (calls *[]string, restore func()) {
+	calls = new([]string)
+	saved := commandRunner
+	commandRunner = func(ctx context.Context, command string, readStdout bool, arguments ...string) (int, string, error) {
+		line := strings.Join(append([]string{command}, arguments...), " ")
+		*calls = append(*calls, line)
+		r, err := answer(ctx, line)
+		if !readStdout {
+			r.stdout = ""
+		}
+		if err != nil || r.exitCode != 0 {
+			return r.exitCode, r.stdout, &CommandError{Command: command, Args: arguments, ExitCode: r.exitCode, Stdout: r.stdout, Err: err}
+		}
+		return 0, r.stdout, nil
+	}
+	return calls, func() { commandRunner = saved }
+}
@@ -0,0 +1,29 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package service
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setCommandProcessGroup starts cmd in its own process group, so killCommand
+// also reaches the scripts it runs.
+func setCommandProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killCommand kills the process group of a command started with
+// setCommandProcessGroup.
+func killCommand(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+func shellCommand(script string) (string, []string) {
+	return "/bin/sh", []string{"-c", script}
+}
@@ -0,0 +1,19 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"os/exec"
+)
+
+func setCommandProcessGroup(cmd *exec.Cmd) {}
+
+func killCommand(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
+
+func shellCommand(script string) (string, []string) {
+	return "cmd", []string{"/C", script}
+}
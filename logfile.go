@@ -0,0 +1,135 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// newFileLogger returns a Logger appending to the file at path. Close closes
+// the file.
+func newFileLogger(path string, errs chan<- error) (Logger, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return fileLogger{
+		f:    f,
+		info: log.New(f, "I: ", log.LstdFlags),
+		warn: log.New(f, "W: ", log.LstdFlags),
+		err:  log.New(f, "E: ", log.LstdFlags),
+		errs: errs,
+	}, nil
+}
+
+type fileLogger struct {
+	f               *os.File
+	info, warn, err *log.Logger
+	errs            chan<- error
+}
+
+func (f fileLogger) Close() error {
+	return f.f.Close()
+}
+
+func (f fileLogger) send(err error) error {
+	if err != nil && f.errs != nil {
+		f.errs <- err
+	}
+	return err
+}
+
+func (f fileLogger) Error(v ...interface{}) error {
+	return f.send(f.err.Output(2, fmt.Sprint(v...)))
+}
+func (f fileLogger) Warning(v ...interface{}) error {
+	return f.send(f.warn.Output(2, fmt.Sprint(v...)))
+}
+func (f fileLogger) Info(v ...interface{}) error {
+	return f.send(f.info.Output(2, fmt.Sprint(v...)))
+}
+func (f fileLogger) Errorf(format string, a ...interface{}) error {
+	return f.send(f.err.Output(2, fmt.Sprintf(format, a...)))
+}
+func (f fileLogger) Warningf(format string, a ...interface{}) error {
+	return f.send(f.warn.Output(2, fmt.Sprintf(format, a...)))
+}
+func (f fileLogger) Infof(format string, a ...interface{}) error {
+	return f.send(f.info.Output(2, fmt.Sprintf(format, a...)))
+}
+
+// tailLogs returns the last lines of each existing file in paths, one after
+// the other. It fails if none of them exists.
+func tailLogs(lines int, paths ...string) ([]byte, error) {
+	if lines <= 0 {
+		return nil, fmt.Errorf("lines must be positive, got %d", lines)
+	}
+	var out []byte
+	found := false
+	for _, path := range paths {
+		b, err := tailFile(path, lines)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		out = append(out, b...)
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no log file found, looked for %s", strings.Join(paths, ", "))
+	}
+	return out, nil
+}
+
+// tailFile returns the last lines of the file at path, reading it backwards.
+func tailFile(path string, lines int) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	const chunkSize = 4096
+	var buf []byte
+	for offset := fi.Size(); offset > 0; {
+		n := int64(chunkSize)
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		chunk := make([]byte, n)
+		if _, err := f.ReadAt(chunk, offset); err != nil {
+			return nil, err
+		}
+		buf = append(chunk, buf...)
+		// A trailing newline ends the last line, it does not start another.
+		if bytes.Count(bytes.TrimSuffix(buf, []byte("\n")), []byte("\n")) >= lines {
+			break
+		}
+	}
+
+	body := bytes.TrimSuffix(buf, []byte("\n"))
+	for i := len(body) - 1; i >= 0; i-- {
+		if body[i] == '\n' {
+			if lines--; lines == 0 {
+				return buf[i+1:], nil
+			}
+		}
+	}
+	return buf, nil
+}
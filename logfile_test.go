@@ -0,0 +1,81 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTailFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var long strings.Builder
+	for i := 1; i <= 2000; i++ {
+		fmt.Fprintf(&long, "line %d\n", i)
+	}
+	tests := []struct {
+		name    string
+		content string
+		lines   int
+		want    string
+	}{
+		{"empty", "", 3, ""},
+		{"fewer lines", "a\nb\n", 3, "a\nb\n"},
+		{"exact", "a\nb\nc\n", 3, "a\nb\nc\n"},
+		{"more lines", "a\nb\nc\nd\n", 2, "c\nd\n"},
+		{"no trailing newline", "a\nb\nc", 2, "b\nc"},
+		{"across chunks", long.String(), 3, "line 1998\nline 1999\nline 2000\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "log")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tailFile(path, tt.lines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("tailFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTailLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	out, errPath, missing := filepath.Join(dir, "out"), filepath.Join(dir, "err"), filepath.Join(dir, "missing")
+	ioutil.WriteFile(out, []byte("1\n2\n3"), 0644)
+	ioutil.WriteFile(errPath, []byte("e1\ne2\n"), 0644)
+
+	got, err := tailLogs(2, out, missing, errPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2\n3\ne1\ne2\n"; string(got) != want {
+		t.Errorf("tailLogs() = %q, want %q", got, want)
+	}
+	if _, err := tailLogs(2, missing); err == nil {
+		t.Error("tailLogs() without log files succeeded, want an error")
+	}
+	if _, err := tailLogs(0, out); err == nil {
+		t.Error("tailLogs(0) succeeded, want an error")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is returned by Run when RecoverPanics is set and the Interface
+// panics in Start or Stop.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+var (
+	panicMu      sync.Mutex
+	panicService Service
+	panicLogger  Logger
+)
+
+// exit ends the process after Go recovered a panic. Tests replace it.
+var exit = os.Exit
+
+// callInterface calls fn with s. When RecoverPanics is set a panic in fn is
+// logged to the Logger of s and returned as a *PanicError, and Go logs to the
+// same Logger.
+func callInterface(s Service, kv KeyValue, fn func(Service) error) (err error) {
+	if !kv.bool(optionRecoverPanics, optionRecoverPanicsDefault) {
+		return fn(s)
+	}
+
+	panicMu.Lock()
+	if panicService != s {
+		panicService, panicLogger = s, nil
+	}
+	panicMu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			perr := &PanicError{Value: r, Stack: debug.Stack()}
+			logPanic(perr)
+			err = perr
+		}
+	}()
+	return fn(s)
+}
+
+// Go runs fn in a new goroutine. If fn panics, the panic and its stack are
+// logged and the process exits with status 2, like an unrecovered panic.
+// The panic is logged to the Logger of the service running with
+// RecoverPanics, or to standard error if there is none.
+func Go(fn func()) {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logPanic(&PanicError{Value: r, Stack: debug.Stack()})
+				exit(2)
+			}
+		}()
+		fn()
+	}()
+}
+
+// logPanic logs perr with the service Logger, created on first use.
+func logPanic(perr *PanicError) {
+	panicMu.Lock()
+	if panicLogger == nil && panicService != nil {
+		if l, err := panicService.Logger(nil); err == nil {
+			panicLogger = l
+		}
+	}
+	logger := panicLogger
+	panicMu.Unlock()
+
+	if logger == nil || logger.Errorf("%v\n%s", perr, perr.Stack) != nil {
+		fmt.Fprintf(os.Stderr, "%v\n%s", perr, perr.Stack)
+	}
+}
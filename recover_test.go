@@ -0,0 +1,96 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+type panicTestLogger struct {
+	errors []string
+}
+
+func (l *panicTestLogger) Error(v ...interface{}) error {
+	l.errors = append(l.errors, fmt.Sprint(v...))
+	return nil
+}
+
+func (l *panicTestLogger) Warning(v ...interface{}) error { return nil }
+func (l *panicTestLogger) Info(v ...interface{}) error    { return nil }
+
+func (l *panicTestLogger) Errorf(format string, a ...interface{}) error {
+	l.errors = append(l.errors, fmt.Sprintf(format, a...))
+	return nil
+}
+
+func (l *panicTestLogger) Warningf(format string, a ...interface{}) error { return nil }
+func (l *panicTestLogger) Infof(format string, a ...interface{}) error    { return nil }
+
+type panicTestService struct {
+	Service
+	logger *panicTestLogger
+}
+
+func (s *panicTestService) Logger(errs chan<- error) (Logger, error) {
+	return s.logger, nil
+}
+
+func TestCallInterfaceRecoverPanics(t *testing.T) {
+	s := &panicTestService{logger: &panicTestLogger{}}
+	err := callInterface(s, KeyValue{optionRecoverPanics: true}, func(Service) error {
+		panic("boom")
+	})
+	if perr, ok := err.(*PanicError); !ok || perr.Value != "boom" {
+		t.Fatalf("callInterface() error = %v, want a *PanicError for boom", err)
+	}
+	if len(s.logger.errors) != 1 {
+		t.Fatalf("logged %d errors, want 1", len(s.logger.errors))
+	}
+	if msg := s.logger.errors[0]; !strings.HasPrefix(msg, "panic: boom\n") || !strings.Contains(msg, "TestCallInterfaceRecoverPanics") {
+		t.Errorf("logged %q, want the panic and its stack", msg)
+	}
+
+	want := errors.New("start failed")
+	if err := callInterface(s, KeyValue{optionRecoverPanics: true}, func(Service) error { return want }); err != want {
+		t.Errorf("callInterface() error = %v, want %v", err, want)
+	}
+}
+
+func TestCallInterfaceWithoutRecoverPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("recover() = %v, want the panic to propagate", r)
+		}
+	}()
+	callInterface(&panicTestService{logger: &panicTestLogger{}}, nil, func(Service) error {
+		panic("boom")
+	})
+}
+
+func TestGoPanicExits(t *testing.T) {
+	if os.Getenv("SERVICE_TEST_GO_PANIC") == "1" {
+		Go(func() { panic("boom") })
+		select {}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe, "-test.run=^TestGoPanicExits$")
+	cmd.Env = append(os.Environ(), "SERVICE_TEST_GO_PANIC=1")
+	out, err := cmd.CombinedOutput()
+	if code, ok := isExitError(err); !ok || code != 2 {
+		t.Fatalf("process exited with %v, want exit status 2:\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "panic: boom\n") || !strings.Contains(string(out), "TestGoPanicExits") {
+		t.Errorf("output is missing the panic and its stack:\n%s", out)
+	}
+}
@@ -62,8 +62,16 @@
 package service // import "github.com/kardianos/service"
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -73,8 +81,10 @@ const (
 	optionRunAtLoadDefault     = false
 	optionUserService          = "UserService"
 	optionUserServiceDefault   = false
+	optionPreferUserService    = "PreferUserService"
 	optionSessionCreate        = "SessionCreate"
 	optionSessionCreateDefault = false
+	optionGroupName            = "GroupName"
 	optionLogOutput            = "LogOutput"
 	optionLogOutputDefault     = false
 	optionPrefix               = "Prefix"
@@ -82,21 +92,185 @@ const (
 
 	optionRunWait            = "RunWait"
 	optionReloadSignal       = "ReloadSignal"
+	optionReloadCommand      = "ReloadCommand"
 	optionPIDFile            = "PIDFile"
 	optionLimitNOFILE        = "LimitNOFILE"
 	optionLimitNOFILEDefault = -1 // -1 = don't set in configuration
 	optionRestart            = "Restart"
+	optionRestartPolicy      = "RestartPolicy"
 
 	optionSuccessExitStatus = "SuccessExitStatus"
 
 	optionSystemdScript = "SystemdScript"
 	optionSysvScript    = "SysvScript"
+	optionSysvTemplate  = "SysvTemplate"
 	optionRCSScript     = "RCSScript"
 	optionUpstartScript = "UpstartScript"
 	optionLaunchdConfig = "LaunchdConfig"
 	optionOpenRCScript  = "OpenRCScript"
+	optionRunitScript   = "RunitScript"
+	optionS6Script      = "S6Script"
+	optionTemplateData  = "TemplateData"
 
 	optionLogDirectory = "LogDirectory"
+	optionLogMaxSize   = "LogMaxSize"
+
+	optionFileMode = "FileMode"
+
+	optionProcessName = "ProcessName"
+
+	restartPolicyNo        = "no"
+	restartPolicyOnFailure = "on-failure"
+	restartPolicyAlways    = "always"
+
+	optionRestartDelay        = "RestartDelay"
+	optionRestartDelayDefault = 50 * time.Millisecond
+
+	optionRestartSec        = "RestartSec"
+	optionRestartSecDefault = time.Second
+
+	optionWaitForService               = "WaitForService"
+	optionWaitForServiceTimeout        = "WaitForServiceTimeout"
+	optionWaitForServiceTimeoutDefault = 30 * time.Second
+
+	optionSELinuxContext  = "SELinuxContext"
+	optionAppArmorProfile = "AppArmorProfile"
+
+	optionRestoreCon = "RestoreCon"
+
+	optionConfigDirectory            = "ConfigDirectory"
+	optionConfigurationDirectoryMode = "ConfigurationDirectoryMode"
+
+	optionDropInOnly        = "DropInOnly"
+	optionDropInOnlyDefault = false
+
+	optionTransient        = "Transient"
+	optionTransientDefault = false
+
+	optionStartLimitAction          = "StartLimitAction"
+	optionStartLimitInterval        = "StartLimitInterval"
+	optionStartLimitIntervalDefault = 5 * time.Second
+	optionStartLimitBurst           = "StartLimitBurst"
+	optionStartLimitBurstDefault    = 10
+
+	optionOnFailureDelayDuration = "OnFailureDelayDuration"
+
+	optionSingleInstance        = "SingleInstance"
+	optionSingleInstanceDefault = false
+
+	optionEnabled        = "Enabled"
+	optionEnabledDefault = true
+
+	optionHealthCheckCommand = "HealthCheckCommand"
+
+	optionReapChildren        = "ReapChildren"
+	optionReapChildrenDefault = false
+
+	optionRecoverPanics        = "RecoverPanics"
+	optionRecoverPanicsDefault = false
+
+	optionAfterNetworkOnline        = "AfterNetworkOnline"
+	optionAfterNetworkOnlineDefault = false
+
+	optionSystemLoggerBackend = "SystemLoggerBackend"
+	systemLoggerSyslog        = "syslog"
+	systemLoggerFile          = "file"
+
+	optionSyslogFacility = "SyslogFacility"
+	optionSyslogTag      = "SyslogTag"
+
+	optionSysVStartBefore = "SysVStartBefore"
+	optionSysVStopAfter   = "SysVStopAfter"
+
+	optionSysVStartPriority        = "SysVStartPriority"
+	optionSysVStartPriorityDefault = 50
+	optionSysVKillPriority         = "SysVKillPriority"
+	optionSysVKillPriorityDefault  = 2
+
+	optionStartRunlevels        = "StartRunlevels"
+	optionStartRunlevelsDefault = "2,3,4,5"
+	optionStopRunlevels         = "StopRunlevels"
+	optionStopRunlevelsDefault  = "0,1,6"
+
+	optionUMask = "UMask"
+
+	optionNice           = "Nice"
+	optionIONiceClass    = "IONiceClass"
+	optionIONicePriority = "IONicePriority"
+
+	optionConditionPathExists = "ConditionPathExists"
+
+	optionSkipExecCheck        = "SkipExecCheck"
+	optionSkipExecCheckDefault = false
+
+	optionBackupOnInstall           = "BackupOnInstall"
+	optionBackupOnInstallDefault    = false
+	optionRestoreOnUninstall        = "RestoreOnUninstall"
+	optionRestoreOnUninstallDefault = false
+
+	optionStartAfterInstall          = "StartAfterInstall"
+	optionStartAfterInstallDefault   = false
+	optionStopBeforeUninstall        = "StopBeforeUninstall"
+	optionStopBeforeUninstallDefault = false
+
+	optionEnvFile = "EnvFile"
+
+	optionCombinedOutput        = "CombinedOutput"
+	optionCombinedOutputDefault = false
+
+	optionForking        = "Forking"
+	optionForkingDefault = false
+
+	optionRootPrefix = "RootPrefix"
+
+	optionPreferInitScript = "PreferInitScript"
+
+	optionArgsFile = "ArgsFile"
+
+	optionMonitIntegration = "MonitIntegration"
+	optionMonitDir         = "MonitDir"
+	optionMonitDirDefault  = "/etc/monit.d"
+
+	optionServiceCommand        = "ServiceCommand"
+	optionServiceCommandDefault = "service"
+
+	optionShell        = "Shell"
+	optionShellDefault = "/bin/sh"
+
+	optionStartRetries           = "StartRetries"
+	optionStartRetriesDefault    = 0
+	optionStartRetryDelay        = "StartRetryDelay"
+	optionStartRetryDelayDefault = time.Second
+
+	optionExpandArgEnv        = "ExpandArgEnv"
+	optionExpandArgEnvDefault = false
+
+	optionStatusTimeout        = "StatusTimeout"
+	optionStatusTimeoutDefault = 5 * time.Second
+
+	optionStopTimeout        = "StopTimeout"
+	optionStopTimeoutDefault = 0
+
+	optionSystem = "System"
+
+	optionInitDir        = "InitDir"
+	optionInitDirDefault = "/etc/init.d"
+
+	optionRunitDir               = "RunitDir"
+	optionRunitDirDefault        = "/etc/sv"
+	optionRunitServiceDir        = "RunitServiceDir"
+	optionRunitServiceDirDefault = "/var/service"
+
+	optionS6Dir                = "S6Dir"
+	optionS6DirDefault         = "/etc/s6/sv"
+	optionS6ScanDir            = "S6ScanDir"
+	optionS6ScanDirDefault     = "/run/service"
+	optionS6RC                 = "S6RC"
+	optionS6RCSourceDir        = "S6RCSourceDir"
+	optionS6RCSourceDirDefault = "/etc/s6-rc/source"
+
+	optionForceKill        = "ForceKill"
+	optionForceKillDefault = false
 )
 
 // Status represents service status as an byte value
@@ -107,8 +281,25 @@ const (
 	StatusUnknown Status = iota // Status is unable to be determined due to an error or it was not installed.
 	StatusRunning
 	StatusStopped
+	// StatusDegraded is a running service whose HealthCheckCommand failed, or
+	// one the service manager reports as degraded, as Solaris SMF does.
+	StatusDegraded
+)
+
+// Capability is a set of optional operations supported by a Service.
+type Capability uint32
+
+// Capabilities a Service may report.
+const (
+	CapabilityUserService Capability = 1 << iota // Can be installed as a user service.
+	CapabilityReload                             // Can reload a running service without a restart.
 )
 
+// Has reports whether c contains all capabilities in o.
+func (c Capability) Has(o Capability) bool {
+	return c&o == o
+}
+
 // Config provides the setup for a Service. The Name field is required.
 type Config struct {
 	Name        string   // Required name of the service. No spaces suggested.
@@ -133,7 +324,7 @@ type Config struct {
 
 	// The following fields are not supported on Windows.
 	WorkingDirectory string // Initial working directory.
-	ChRoot           string
+	ChRoot           string // Root directory the service runs in. System V starts it in the root, not WorkingDirectory.
 
 	// System specific options.
 	Option KeyValue
@@ -146,6 +337,20 @@ var (
 	systemRegistry []System
 )
 
+// OnOperation is called, if set, after each Install, Uninstall, Start, Stop,
+// Restart and Status of a System V service with the name of the operation,
+// such as "start", how long it took and the error it returned. Restart also
+// reports the stop and start it runs. It is called on the goroutine of the
+// operation, possibly concurrently, so it must be cheap and must not block.
+var OnOperation func(op string, d time.Duration, err error)
+
+// observe calls OnOperation for op, which started at start and returned *err.
+func observe(op string, start time.Time, err *error) {
+	if f := OnOperation; f != nil {
+		f(op, time.Since(start), *err)
+	}
+}
+
 var (
 	// ErrNameFieldRequired is returned when Config.Name is empty.
 	ErrNameFieldRequired = errors.New("Config.Name field is required.")
@@ -153,17 +358,147 @@ var (
 	ErrNoServiceSystemDetected = errors.New("No service system detected.")
 	// ErrNotInstalled is returned when the service is not installed.
 	ErrNotInstalled = errors.New("the service is not installed")
+	// ErrNotSupported is returned when the service system does not support the operation.
+	// Errors naming the operation, such as for UserService on System V, unwrap to it.
+	ErrNotSupported = errors.New("the operation is not supported by the service system")
+	// ErrAlreadyRunning is returned by Run when another instance holds the pid file lock.
+	ErrAlreadyRunning = errors.New("the service is already running")
 )
 
+// CommandError is returned when a command run to control the service fails.
+type CommandError struct {
+	Command  string   // Command that was run.
+	Args     []string // Arguments passed to Command.
+	ExitCode int      // Exit code, zero if the command did not exit with a status.
+	Stdout   string   // Standard output, if it was read.
+	Stderr   string   // Standard error.
+	Err      error    // Underlying error, nil if the failure was detected from Stderr alone.
+}
+
+func (e *CommandError) Error() string {
+	msg := fmt.Sprintf("%q failed", strings.Join(append([]string{e.Command}, e.Args...), " "))
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if stderr := strings.TrimSpace(e.Stderr); stderr != "" {
+		msg += " with stderr: " + stderr
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// notSupportedError is returned for an operation or mode a service system
+// lacks. It unwraps to ErrNotSupported.
+type notSupportedError struct {
+	op       string
+	platform string
+}
+
+func notSupported(op, platform string) error {
+	return &notSupportedError{op: op, platform: platform}
+}
+
+func (e *notSupportedError) Error() string {
+	return e.op + " is not supported on " + e.platform
+}
+
+// Unwrap returns ErrNotSupported.
+func (e *notSupportedError) Unwrap() error {
+	return ErrNotSupported
+}
+
+// durationOptions lists the options holding a time.Duration. KeyValue may
+// also hold them as a time.Duration string.
+var durationOptions = []string{
+	optionRestartDelay,
+	optionWaitForServiceTimeout,
+	optionOnFailureDelayDuration,
+	optionStartLimitInterval,
+	optionRestartSec,
+	optionStatusTimeout,
+	optionStartRetryDelay,
+	optionStopTimeout,
+}
+
+// modeOptions lists the options holding permission bits. KeyValue may also
+// hold them as an octal string such as "0750".
+var modeOptions = []string{
+	optionFileMode,
+	optionConfigurationDirectoryMode,
+}
+
+// Validate checks the duration and mode options in Option and replaces
+// duration and octal strings with their time.Duration and os.FileMode value.
+// New calls Validate, so misconfigured options are reported before anything
+// is installed.
+func (c *Config) Validate() error {
+	for _, name := range modeOptions {
+		v, ok := c.Option[name].(string)
+		if !ok {
+			continue
+		}
+		m, err := strconv.ParseUint(v, 8, 32)
+		if err != nil || m > 0777 {
+			return fmt.Errorf("option %s: invalid octal file mode %q", name, v)
+		}
+		c.Option[name] = os.FileMode(m)
+	}
+	for _, name := range durationOptions {
+		v, found := c.Option[name]
+		if !found {
+			continue
+		}
+		var d time.Duration
+		switch v := v.(type) {
+		case time.Duration:
+			d = v
+		case string:
+			var err error
+			if d, err = time.ParseDuration(v); err != nil {
+				return fmt.Errorf("option %s: invalid duration %q", name, v)
+			}
+		default:
+			return fmt.Errorf("option %s: want a time.Duration or duration string, got %T", name, v)
+		}
+		if d < 0 {
+			return fmt.Errorf("option %s: duration must not be negative, got %v", name, d)
+		}
+		c.Option[name] = d
+	}
+	return nil
+}
+
 // New creates a new service based on a service interface and configuration.
 func New(i Interface, c *Config) (Service, error) {
 	if len(c.Name) == 0 {
 		return nil, ErrNameFieldRequired
 	}
-	if system == nil {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	sys := system
+	if name := c.Option.string(optionSystem, ""); name != "" {
+		sys = nil
+		var names []string
+		for _, choice := range systemRegistry {
+			if choice.String() == name {
+				sys = choice
+				break
+			}
+			names = append(names, choice.String())
+		}
+		if sys == nil {
+			return nil, fmt.Errorf("unknown %s %q, want one of %s", optionSystem, name, strings.Join(names, ", "))
+		}
+	}
+	if sys == nil {
 		return nil, ErrNoServiceSystemDetected
 	}
-	return system.New(i, c)
+	return sys.New(i, c)
 }
 
 // KeyValue provides a list of system specific options.
@@ -178,42 +513,279 @@ func New(i Interface, c *Config) (Service, error) {
 //
 //   - SessionCreate bool   (false)            - Create a full user session.
 //
+//   - GroupName     string ()                 - Run as group. Log files are owned by UserName and GroupName.
+//
+//   - WaitForService        string ()         - Launchd label or file path Run waits for before calling Start.
+//     Launchd has no start ordering, this only delays Start until the label is loaded or the path exists.
+//
+//   - WaitForServiceTimeout string ("30s")    - Maximum time Run waits for WaitForService, time.Duration string.
+//
 //   - Solaris
 //
 //   - Prefix        string ("application")    - Service FMRI prefix.
 //
 //   - POSIX
 //
+//   - System        string ()                 - Platform name of the system New uses instead of the
+//     detected one, such as "unix-systemv" or "linux-systemd", see Platform and AvailableSystems.
+//     It is used even if it is not detected. Also used on OS X and Windows.
+//
 //   - UserService   bool   (false)            - Install as a current user service.
 //
+//   - PreferUserService bool (false)          - Install as a current user service when not running as root.
+//     Only systemd and OS X, other systems install a system service.
+//
 //   - SystemdScript string ()                 - Use custom systemd script.
 //
 //   - UpstartScript string ()                 - Use custom upstart script.
 //
 //   - SysvScript    string ()                 - Use custom sysv script.
 //
+//   - SysvTemplate  *template.Template ()     - Parsed text/template of a custom sysv script, used
+//     instead of SysvScript so one vetted template can be shared by many services. It must define
+//     any functions it calls itself. Install checks that it executes before writing the init script.
+//
+//   - TemplateData  map[string]interface{} () - Values a custom sysv script reads as {{.Extra.<key>}}.
+//     They are only available under .Extra, so they never replace the fields the built-in script uses.
+//
 //   - OpenRCScript  string ()                 - Use custom OpenRC script.
 //
+//   - RunitScript   string ()                 - Use custom runit run script.
+//
+//   - RunitDir      string (/etc/sv)          - Directory runit service directories are installed in.
+//
+//   - RunitServiceDir string (/var/service)   - Directory runsvdir supervises. Install links the
+//     service directory into it unless Enabled is false, and sv controls the service through the link,
+//     so Start and Stop fail until the service is enabled.
+//
+//   - S6Script      string ()                 - Use custom s6 run script.
+//
+//   - S6Dir         string (/etc/s6/sv)       - Directory s6 service directories are installed in.
+//
+//   - S6ScanDir     string (/run/service)     - Directory s6-svscan supervises. Install links the service
+//     directory into it, with a down file if Enabled is false, and s6-svc controls it through the link.
+//
+//   - S6RC          bool   (s6-rc installed)  - Install defines a longrun in S6RCSourceDir instead, added to
+//     the default bundle unless Enabled is false, and s6-rc starts and stops it. The s6-rc database must
+//     be compiled and updated with s6-rc-compile and s6-rc-update before it knows of the service.
+//
+//   - S6RCSourceDir string (/etc/s6-rc/source) - Source directory of the s6-rc service definitions.
+//
 //   - RunWait       func() (wait for SIGNAL)  - Do not install signal but wait for this function to return.
 //
 //   - ReloadSignal  string () [USR1, ...]     - Signal to send on reload.
 //
+//   - ReloadCommand string ()                 - Shell command run by the System V init script on reload,
+//     instead of sending ReloadSignal. $(get_pid) expands to the service pid.
+//
 //   - PIDFile       string () [/run/prog.pid] - Location of the PID file.
+//     Defaults to <Name>.pid in /run on System V, or in /var/run on a system without /run.
 //
 //   - LogOutput     bool   (false)            - Redirect StdErr & StandardOutPath to files.
 //
 //   - Restart       string (always)           - How shall service be restarted.
 //
+//   - RestartPolicy string ()                 - Portable restart policy (no | on-failure | always),
+//     translated to Restart on systemd, KeepAlive on OS X, respawn on Upstart, daemon -r on FreeBSD,
+//     a supervisor loop in the System V init script and OnFailure on Windows. The platform
+//     specific option wins when both are set. Windows can only restart after a failure, so
+//     always behaves as on-failure there.
+//
+//   - RestartDelay  time.Duration (50ms)      - Pause between stop and start in Restart, a
+//     time.Duration or time.Duration string. Not used by systemd, Upstart, FreeBSD or Solaris.
+//
 //   - SuccessExitStatus string ()             - The list of exit status that shall be considered as successful,
 //     in addition to the default ones.
 //
 //   - LogDirectory string(/var/log)           - The path to the log files directory
+//     System V resolves a relative path against WorkingDirectory.
+//
+//   - FileMode      os.FileMode ()            - Permissions of the unit, script or plist written by Install,
+//     set regardless of the umask. Defaults to 0755 for init scripts and 0644 otherwise. May be
+//     an octal string such as "0750". A System V init script must be readable and executable by its owner.
+//
+//   - AfterNetworkOnline bool (false)         - Start after the network is up: network-online.target on
+//     systemd, $network $remote_fs on System V, need net on OpenRC, net-device-up on Upstart and
+//     NETWORKING on FreeBSD. Solaris always waits for the network, OS X has no equivalent.
+//
+//   - HealthCheckCommand string ()            - Shell command run by Status when the service manager
+//     reports the service as running. A non-zero exit reports StatusDegraded. If the command can not
+//     be run Status returns StatusUnknown with the command error.
+//     Also used on Windows, run with cmd /C. On systemd Status keeps the state of the unit, the
+//     check is run by CombinedStatus, which sets Degraded.
+//
+//   - ReapChildren  bool   (false)            - Run reaps every terminated child process on SIGCHLD,
+//     for services that are a container entrypoint or supervise orphans. Children started with
+//     os/exec are reaped too, so their Wait returns an error. No-op on Windows.
+//
+//   - RecoverPanics bool   (false)            - Run recovers a panic in Start or Stop, logs it with its
+//     stack to the service Logger and returns it as a *PanicError. Go logs to the same Logger.
+//
+//   - ProcessName   string ()                 - Process name shown by ps and top, set by Run.
+//     Linux and OS X only, ignored on Windows. Linux truncates the name to 15 bytes.
 //
 //   - Linux (systemd)
 //
 //   - LimitNOFILE   int    (-1)               - Maximum open files (ulimit -n)
 //     (https://serverfault.com/questions/628610/increasing-nproc-for-processes-launched-by-systemd-on-centos-7)
 //
+//   - SELinuxContext string ()                - SELinux security context the service runs in (SELinuxContext=).
+//
+//   - AppArmorProfile string ()               - AppArmor profile the service runs in (AppArmorProfile=).
+//
+//   - RestoreCon    bool   (true if restorecon is installed) - Install runs restorecon on the unit
+//     file so it gets the SELinux file context systemd can load.
+//
+//   - ConfigDirectory string ()               - Directory below /etc that systemd creates and hands to
+//     User (ConfigurationDirectory=), relative. Left out before systemd 235.
+//
+//   - ConfigurationDirectoryMode os.FileMode () - Permissions of ConfigDirectory, 0755 if not set.
+//
+//   - StartLimitInterval time.Duration (5s)   - Interval the start limit is counted over.
+//
+//   - StartLimitBurst int  (10)               - Starts allowed within StartLimitInterval.
+//
+//   - StartLimitAction string ()              - Action taken when the start limit is hit, one of none,
+//     reboot, reboot-force, reboot-immediate, poweroff, poweroff-force, poweroff-immediate, exit,
+//     exit-force, soft-reboot, soft-reboot-force, kexec, kexec-force, halt, halt-force, halt-immediate.
+//
+//   - DropInOnly    bool   (false)            - Never write the main unit file. Install writes
+//     <name>.service.d/override.conf instead and fails if no main unit is installed.
+//
+//   - Transient     bool   (false)            - Run as a transient unit without a unit file. Start runs
+//     systemd-run with the other options as unit properties, Install and Uninstall do nothing.
+//
+//   - Linux (System V)
+//
+//   - InitDir       string (/etc/init.d)      - Absolute directory the init script is installed to, also
+//     used by rcS. The runlevel links go to the rc<N>.d directories next to it, rc.d on rcS. Outside
+//     the default the script is run directly instead of with service.
+//
+//   - SingleInstance bool  (false)            - Run holds an exclusive lock on PIDFile and returns
+//     ErrAlreadyRunning if another instance already holds it.
+//
+//   - Enabled       bool   (true)             - Install creates the runlevel symlinks that start the
+//     service at boot. When false only the init script is written, see Enable and Disable.
+//     Missing runlevel directories are skipped, Install fails with the init script in place if
+//     a symlink can not be created or the service would start in no runlevel.
+//     On FreeBSD Install sets the <Name>_enable rcvar to YES with sysrc instead, on OpenBSD it runs rcctl enable.
+//
+//   - SysVStartBefore string ()               - Space separated services this one starts before,
+//     written as the LSB X-Start-Before header. This only orders startup, it is not a dependency.
+//
+//   - SysVStopAfter string ()                 - Space separated services this one stops after,
+//     written as the LSB X-Stop-After header.
+//
+//   - SysVStartPriority int (50)              - Sequence number of the S<NN> runlevel symlinks, 0 to 99.
+//
+//   - SysVKillPriority  int (2)               - Sequence number of the K<NN> runlevel symlinks, 0 to 99.
+//     Both are also written to the chkconfig header.
+//
+//   - StartRunlevels string (2,3,4,5)         - Comma separated runlevels from 0 to 6 the service is started
+//     in, written as the S<NN> symlinks and the Default-Start and chkconfig headers.
+//
+//   - StopRunlevels string (0,1,6)            - Comma separated runlevels from 0 to 6 the service is stopped
+//     in, written as the K<NN> symlinks and the Default-Stop header.
+//
+//   - PreferInitScript bool (false)           - Control the service by running the init script directly
+//     instead of with the service command. The init script is also run directly if there is no
+//     service command, as on OpenWrt.
+//
+//   - ServiceCommand string (service)         - Name in PATH or path of the command run as
+//     <ServiceCommand> <Name> <action> to control a service in /etc/init.d, such as a wrapper script.
+//
+//   - Shell         string (/bin/sh)          - Absolute path of the interpreter in the #! line of the
+//     init script, for ReloadCommand or an EnvFile that needs more than a POSIX shell.
+//
+//   - RootPrefix    string ()                 - Absolute directory prepended to every path Install,
+//     Uninstall, Enable and Disable write, for staging a package build. The init script and the
+//     runlevel symlinks still refer to the live paths. Install and Uninstall do not check the
+//     executable or run the service command, see SkipExecCheck, StartAfterInstall and StopBeforeUninstall.
+//
+//   - Forking       bool   (false)            - The executable daemonizes itself and writes its pid to
+//     PIDFile. The init script waits for it to exit and then for PIDFile instead of recording
+//     the pid of the process it started. Can not be combined with RestartPolicy.
+//
+//   - StartRetries  int    (0)                - Times Start runs the start command again if it fails.
+//
+//   - StartRetryDelay time.Duration (1s)      - Pause before the first retry, doubled before each next one.
+//
+//   - CombinedOutput bool  (false)            - The init script redirects standard error to
+//     LogDirectory/<Name>.log along with standard output instead of to LogDirectory/<Name>.err.
+//
+//   - LogMaxSize    int    (0)                - Size in bytes above which the init script start renames
+//     each log file to <file>.1, replacing an older one, and begins the new file with a timestamped
+//     marker. Only checked when the service starts, so a long running service can still exceed it;
+//     this is a guard for hosts without logrotate, not a rotation solution. Zero never rotates.
+//
+//   - SyslogFacility string ()                - Facility of the syslog SystemLogger, such as daemon or
+//     local0 to local7. The syslog default if empty.
+//
+//   - SyslogTag     string (Name)             - Tag of the syslog SystemLogger.
+//
+//   - SystemLoggerBackend string (syslog)     - Where SystemLogger writes, syslog or file. file appends
+//     to LogDirectory/<Name>.log, the file the init script redirects standard output to.
+//
+//   - RestartSec    time.Duration (1s)        - Pause before the init script restarts the service
+//     under RestartPolicy on-failure or always.
+//
+//   - StatusTimeout time.Duration (5s)        - Status gives up on a hung init script after this long and
+//     returns StatusUnknown with the error. Zero waits forever, StatusContext uses its context.
+//
+//   - StopTimeout   time.Duration (0)         - Restart and StopBeforeUninstall wait up to this long for
+//     Status to report the service stopped after Stop. For Restart zero relies on the init script,
+//     whose stop waits up to 10 seconds.
+//
+//   - ArgsFile      string ()                 - Absolute path of a file Install writes Arguments to, one
+//     quoted argument per line, which the init script reads when it starts the service instead of
+//     listing them. It gets the FileMode of the init script without the exec bits. Uninstall
+//     removes it.
+//
+//   - MonitIntegration bool (false)           - Install also writes MonitDir/<Name>.conf, a monit check
+//     of PIDFile that starts and stops the service with the init script, so monit respawns it.
+//     Uninstall removes it.
+//
+//   - MonitDir      string (/etc/monit.d)     - Directory monit includes the fragment of MonitIntegration from.
+//
+//   - ExpandArgEnv  bool   (false)            - Quote Arguments in the init script so that $NAME and
+//     ${NAME} environment references expand when it starts the service. Other shell syntax stays literal.
+//     Either way each argument is passed as is, including any newlines and tabs.
+//
+//   - SkipExecCheck bool   (false)            - Install does not check that the executable exists and
+//     can be executed by UserName, for a binary deployed after the service is installed.
+//
+//   - BackupOnInstall bool (false)            - Install replaces an existing init script, which is kept
+//     as <name>.bak-<timestamp> next to it, instead of failing.
+//
+//   - RestoreOnUninstall bool (false)         - Uninstall puts the most recent <name>.bak-<timestamp>
+//     back in place of the init script instead of only removing it.
+//
+//   - StartAfterInstall bool (false)          - Install starts the service as its last step. The init
+//     script stays installed if it fails to start.
+//
+//   - StopBeforeUninstall bool (false)        - Uninstall stops the service if it is running and waits up
+//     to StopTimeout, or 5 seconds if zero, for it to exit before the init script is removed. A service
+//     that does not stop is killed with SIGKILL using PIDFile. The init script stays if it is still running.
+//
+//   - EnvFile string ()                       - Absolute path of a file the init script sources if it exists.
+//     Both /etc/sysconfig/<name> and /etc/default/<name> are sourced if empty.
+//
+//   - ConditionPathExists string ()           - The init script start does nothing and exits 0 when this
+//     path does not exist, for example a volume that is not mounted.
+//
+//   - UMask         string ()                 - Octal umask the init script sets before starting the
+//     service, for example "027".
+//
+//   - Nice          int    ()                 - Niceness from -20 to 19 the init script starts the service with.
+//
+//   - IONiceClass   int    ()                 - IO scheduling class the init script starts the service with,
+//     1 realtime, 2 best-effort or 3 idle. Ignored if ionice is not installed.
+//
+//   - IONicePriority int   ()                 - IO priority from 0 to 7 within IONiceClass 1 or 2.
+//
+//   - ForceKill     bool   (false)            - The init script stop sends SIGKILL when the service is
+//     still running after the 10 second grace period, instead of failing with exit status 1.
+//
 //   - Windows
 //
 //   - DelayedAutoStart  bool (false)                - After booting, start this service after some delay.
@@ -228,7 +800,7 @@ func New(i Interface, c *Config) (Service, error) {
 //
 //   - OnFailure               string ("restart" )   - Action to perform on service failure. (restart | reboot | noaction)
 //
-//   - OnFailureDelayDuration  string ( "1s" )       - Delay before restarting the service, time.Duration string.
+//   - OnFailureDelayDuration  time.Duration ("1s") - Delay before restarting the service, a time.Duration or time.Duration string.
 //
 //   - OnFailureResetPeriod    int ( 10 )            - Reset period for errors, seconds.
 type KeyValue map[string]interface{}
@@ -277,6 +849,23 @@ func (kv KeyValue) float64(name string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// duration returns the value of the given name, assuming the value is a
+// time.Duration or a string parsed by time.ParseDuration.
+// If the value isn't found or is not of the type, the defaultValue is returned.
+func (kv KeyValue) duration(name string, defaultValue time.Duration) time.Duration {
+	if v, found := kv[name]; found {
+		switch castValue := v.(type) {
+		case time.Duration:
+			return castValue
+		case string:
+			if d, err := time.ParseDuration(castValue); err == nil {
+				return d
+			}
+		}
+	}
+	return defaultValue
+}
+
 // funcSingle returns the value of the given name, assuming the value is a func().
 // If the value isn't found or is not of the type, the defaultValue is returned.
 func (kv KeyValue) funcSingle(name string, defaultValue func()) func() {
@@ -288,7 +877,87 @@ func (kv KeyValue) funcSingle(name string, defaultValue func()) func() {
 	return defaultValue
 }
 
-// Platform returns a description of the system service.
+// restartPolicy returns the RestartPolicy option, empty if it is not set.
+func restartPolicy(kv KeyValue) (string, error) {
+	policy := kv.string(optionRestartPolicy, "")
+	switch policy {
+	case "", restartPolicyNo, restartPolicyOnFailure, restartPolicyAlways:
+		return policy, nil
+	}
+	return "", fmt.Errorf("invalid %s %q, want %s, %s or %s", optionRestartPolicy, policy,
+		restartPolicyNo, restartPolicyOnFailure, restartPolicyAlways)
+}
+
+// fileMode returns the FileMode option, the permission bits Install sets on
+// the files it writes regardless of the umask.
+func fileMode(kv KeyValue, defaultMode os.FileMode) os.FileMode {
+	return modeOption(kv, optionFileMode, defaultMode)
+}
+
+// modeOption returns the permission bits of the option name, an os.FileMode
+// or an int.
+func modeOption(kv KeyValue, name string, defaultMode os.FileMode) os.FileMode {
+	switch v := kv[name].(type) {
+	case os.FileMode:
+		return v.Perm()
+	case int:
+		return os.FileMode(v).Perm()
+	}
+	return defaultMode
+}
+
+// restartDelay returns the pause between stop and start in Restart.
+func restartDelay(kv KeyValue) (time.Duration, error) {
+	d := kv.duration(optionRestartDelay, optionRestartDelayDefault)
+	if d < 0 {
+		return 0, fmt.Errorf("%s must not be negative, got %v", optionRestartDelay, d)
+	}
+	return d, nil
+}
+
+var safeNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// validateName returns an error if name can not be used as is in a file name
+// or symlink of an init script.
+func validateName(name string) error {
+	if !safeNameRe.MatchString(name) {
+		return fmt.Errorf("invalid service name %q: only letters, digits, '-' and '_' are allowed", name)
+	}
+	return nil
+}
+
+// checkHealth runs HealthCheckCommand if the service manager reports the
+// service as running. A check that exits non-zero turns the status into
+// StatusDegraded, a check that can not be run into StatusUnknown with the
+// CommandError of the check.
+func checkHealth(ctx context.Context, kv KeyValue, status Status, err error) (Status, error) {
+	command := kv.string(optionHealthCheckCommand, "")
+	if command == "" || err != nil || status != StatusRunning {
+		return status, err
+	}
+	if err := runShellContext(ctx, command); err != nil {
+		if cmdErr, ok := err.(*CommandError); ok && cmdErr.ExitCode > 0 {
+			return StatusDegraded, nil
+		}
+		return StatusUnknown, err
+	}
+	return StatusRunning, nil
+}
+
+// Platform returns a description of the system service. It is the String of
+// ChosenSystem and is one of the following, which do not change between
+// releases and may be compared against:
+//
+//   - Linux: "linux-systemd", "linux-upstart", "linux-runit", "linux-s6",
+//     "linux-openrc", "linux-rcs" or "unix-systemv", detected in that order.
+//   - OS X: "darwin-launchd".
+//   - Windows: "windows-service".
+//   - FreeBSD: "freebsd".
+//   - OpenBSD: "openbsd".
+//   - Solaris: "solaris-smf".
+//   - AIX: "aix-ssrc".
+//
+// It is "" if no system service is available.
 func Platform() string {
 	if system == nil {
 		return ""
@@ -296,8 +965,26 @@ func Platform() string {
 	return system.String()
 }
 
+// InstalledServices returns the names of the System V init scripts in
+// /etc/init.d that were installed by this package. Returns ErrNotSupported
+// on platforms without System V init scripts.
+func InstalledServices() ([]string, error) {
+	return installedServices(optionInitDirDefault)
+}
+
 // Interactive returns false if running under the OS service manager
-// and true otherwise.
+// and true otherwise. It is determined once at startup:
+//
+//   - Linux: false if the parent process is PID 1 or systemd, true inside a
+//     docker or lxc container.
+//   - OS X and Solaris: false if the parent process is PID 1.
+//   - FreeBSD and OpenBSD: false if the IS_DAEMON environment variable is 1,
+//     as set by the rc.d script.
+//   - AIX: false if the parent process is srcmstr.
+//   - Windows: false if the process was started by the service control manager.
+//
+// Logger uses it to pick the console or the system logger, main may use it
+// the same way.
 func Interactive() bool {
 	if system == nil {
 		return true
@@ -323,7 +1010,9 @@ func ChooseSystem(a ...System) {
 	system = newSystem()
 }
 
-// ChosenSystem returns the system that service will use.
+// ChosenSystem returns the system that service will use, or nil if none is
+// available. It is known before New is called, so a program can report or
+// branch on the service manager it will install into.
 func ChosenSystem() System {
 	return system
 }
@@ -385,6 +1074,25 @@ type Shutdowner interface {
 	Shutdown(s Service) error
 }
 
+// Signaler represents a service interface for a program that wants to know
+// which signal Run received. On Unix, Run then also handles SIGHUP: OnSignal
+// is called and the service keeps running, for example to reload its
+// configuration. For the signals that stop the service OnSignal is called
+// before Stop.
+type Signaler interface {
+	Interface
+	OnSignal(s Service, sig os.Signal)
+}
+
+// Reloader represents a service interface for a program that reloads its
+// configuration on SIGHUP. Run keeps the service running after Reload, an
+// error is logged. SIGQUIT stops a Reloader like SIGTERM. Reload is not
+// called on Windows.
+type Reloader interface {
+	Interface
+	Reload(s Service) error
+}
+
 // TODO: Add Configure to Service interface.
 
 // Service represents a service that can be run or controlled.
@@ -433,6 +1141,195 @@ type Service interface {
 	Status() (Status, error)
 }
 
+// RunResult describes the last finished run of a service.
+type RunResult struct {
+	ExitCode   int       // Exit code of the main process.
+	FinishedAt time.Time // Time the run finished, zero if unknown or it never ran.
+	Success    bool      // The service manager considered the run successful.
+}
+
+// ServiceStatus is the status of a service in a form common to all platforms.
+// Fields the platform does not expose are left zero.
+type ServiceStatus struct {
+	State     Status    // Status as returned by Status.
+	PID       int       // Main process, zero if it is not running or unknown.
+	Since     time.Time // Time the service entered State, zero if unknown.
+	LastError error     // Error of Status, or the last failure of the service.
+	Degraded  bool      // HealthCheckCommand failed on systemd, where State stays the unit state.
+}
+
+// CombinedStatuser is implemented by a Service that can report more than its
+// Status, see CombinedStatus.
+type CombinedStatuser interface {
+	CombinedStatus() ServiceStatus
+}
+
+// CombinedStatus returns the status of s. A Service that does not implement
+// CombinedStatuser only reports State and LastError.
+func CombinedStatus(s Service) ServiceStatus {
+	if c, ok := s.(CombinedStatuser); ok {
+		return c.CombinedStatus()
+	}
+	state, err := s.Status()
+	return ServiceStatus{State: state, LastError: err}
+}
+
+// CapabilityReporter is implemented by a Service that supports optional
+// operations, see Capabilities.
+type CapabilityReporter interface {
+	// Capabilities reports the optional operations the service system
+	// supports for this service.
+	Capabilities() Capability
+}
+
+// Capabilities returns the optional operations s supports. A Service that
+// does not implement CapabilityReporter supports none.
+func Capabilities(s Service) Capability {
+	if c, ok := s.(CapabilityReporter); ok {
+		return c.Capabilities()
+	}
+	return 0
+}
+
+// RunResulter is implemented by a Service whose service system records how
+// its last run ended, see LastRunResult.
+type RunResulter interface {
+	// LastRunResult reports how the last run of the service ended, which is
+	// mostly useful for oneshot or scheduled jobs.
+	LastRunResult() (*RunResult, error)
+}
+
+// LastRunResult reports how the last run of s ended. Returns ErrNotSupported
+// if s does not implement RunResulter.
+func LastRunResult(s Service) (*RunResult, error) {
+	if r, ok := s.(RunResulter); ok {
+		return r.LastRunResult()
+	}
+	return nil, ErrNotSupported
+}
+
+// RunNower is implemented by a Service whose service system can run it on a
+// schedule, see RunNow.
+type RunNower interface {
+	// RunNow runs a scheduled service immediately instead of waiting for its
+	// schedule. Returns ErrNotSupported if the service is not scheduled.
+	RunNow() error
+}
+
+// RunNow runs the scheduled service s immediately. Returns ErrNotSupported if
+// s does not implement RunNower.
+func RunNow(s Service) error {
+	if r, ok := s.(RunNower); ok {
+		return r.RunNow()
+	}
+	return ErrNotSupported
+}
+
+// DisableStopper is implemented by a Service that can be disabled and stopped
+// in one step, see DisableAndStop.
+type DisableStopper interface {
+	// DisableAndStop prevents the service from starting at boot, then stops
+	// it. The service stays installed.
+	DisableAndStop() error
+}
+
+// DisableAndStop prevents s from starting at boot, then stops it. Returns
+// ErrNotSupported if s does not implement DisableStopper.
+func DisableAndStop(s Service) error {
+	if d, ok := s.(DisableStopper); ok {
+		return d.DisableAndStop()
+	}
+	return ErrNotSupported
+}
+
+// InstallResult describes how a service was installed.
+type InstallResult struct {
+	// UserService is true if the service was installed as a current user
+	// service rather than a system service.
+	UserService bool
+}
+
+// InstallResulter is implemented by a Service that can report how it was
+// installed.
+type InstallResulter interface {
+	// InstallWithResult installs the service like Install and describes the
+	// resulting installation.
+	InstallWithResult() (InstallResult, error)
+}
+
+// Reinstaller is implemented by a Service that can replace its installation
+// with one for the current Config in a single step.
+type Reinstaller interface {
+	// Reinstall installs the service again, leaving the previous installation
+	// in place if it fails. A service that is not installed is installed.
+	Reinstall() error
+}
+
+// InstallChecker is implemented by a Service that can tell whether it is
+// installed without running the service manager.
+type InstallChecker interface {
+	// Installed reports whether the service is installed.
+	Installed() (bool, error)
+}
+
+// FileAction is what a FileOp does to its Path.
+type FileAction string
+
+// File actions of an InstallPlan.
+const (
+	FileCreate  FileAction = "create"  // Write Path.
+	FileSymlink FileAction = "symlink" // Create Path as a symlink to Target.
+	FileRename  FileAction = "rename"  // Move Path to Target.
+	FileRemove  FileAction = "remove"  // Remove Path.
+)
+
+// FileOp is a change Install would make to the file system.
+type FileOp struct {
+	Path   string
+	Action FileAction
+	Target string // Target of a symlink or rename.
+}
+
+// InstallPlanner is implemented by a Service that can tell which files
+// Install would change without changing them.
+type InstallPlanner interface {
+	// InstallPlan returns the file operations of the next Install in order,
+	// or the error Install would fail with before touching the file system.
+	InstallPlan() ([]FileOp, error)
+}
+
+// Verifier is implemented by a Service that can check the script Install
+// would write without installing it.
+type Verifier interface {
+	// Verify returns an error describing the first syntax error of the
+	// rendered script, with the offending line, or nil if it parses.
+	Verify() error
+}
+
+// ContextController is implemented by a Service whose control commands can be
+// canceled or bounded by a context.
+type ContextController interface {
+	StartContext(ctx context.Context) error
+	StopContext(ctx context.Context) error
+	StatusContext(ctx context.Context) (Status, error)
+}
+
+// LogReader is implemented by a Service that can return its recent output.
+type LogReader interface {
+	// Logs returns the last lines of the service output.
+	Logs(lines int) ([]byte, error)
+}
+
+// PropertyReader is implemented by a Service that can return the properties
+// the service manager holds for it, for those not covered by other methods.
+type PropertyReader interface {
+	// Properties returns the properties of the installed service. Keys and
+	// values are platform specific: systemctl show on systemd, launchctl
+	// print on OS X, sc qc and sc qfailure on Windows and the variables set
+	// by the init script on System V.
+	Properties() (map[string]string, error)
+}
+
 // ControlAction list valid string texts to use in Control.
 var ControlAction = [5]string{"start", "stop", "restart", "install", "uninstall"}
 
@@ -469,3 +1366,43 @@ type Logger interface {
 	Warningf(format string, a ...interface{}) error
 	Infof(format string, a ...interface{}) error
 }
+
+// Flusher is implemented by a Logger that buffers its output. On System V,
+// Run flushes the Loggers returned by Logger and SystemLogger when it returns.
+// A Logger that is an io.Closer but not a Flusher is closed instead. The other
+// backends leave flushing and closing their Loggers to the caller.
+type Flusher interface {
+	Flush() error
+}
+
+// loggerSet records the Loggers a service returned so Run can flush them.
+type loggerSet struct {
+	mu      sync.Mutex
+	loggers []Logger
+}
+
+// add records l and returns it with err.
+func (ls *loggerSet) add(l Logger, err error) (Logger, error) {
+	if err == nil {
+		ls.mu.Lock()
+		ls.loggers = append(ls.loggers, l)
+		ls.mu.Unlock()
+	}
+	return l, err
+}
+
+// flush flushes or closes each recorded Logger and forgets it. A closed
+// syslog Logger reconnects if it is used again.
+func (ls *loggerSet) flush() {
+	ls.mu.Lock()
+	loggers := ls.loggers
+	ls.loggers = nil
+	ls.mu.Unlock()
+	for _, l := range loggers {
+		if f, ok := l.(Flusher); ok {
+			f.Flush()
+		} else if c, ok := l.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
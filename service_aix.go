@@ -9,14 +9,13 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
-	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
-	"syscall"
 	"text/template"
 	"time"
 )
@@ -25,6 +24,9 @@ const maxPathSize = 32 * 1024
 
 const version = "aix-ssrc"
 
+// wnohang is WNOHANG from <sys/wait.h>, syscall does not define it on AIX.
+const wnohang = 0x1
+
 type aixSystem struct{}
 
 func (aixSystem) String() string {
@@ -117,18 +119,43 @@ func (s *aixService) configPath() (cp string, err error) {
 	return
 }
 
-func (s *aixService) Install() error {
-	// install service
-	path, err := s.execPath()
-	if err != nil {
-		return err
+// aixEtcDir holds the rc directories. Tests replace it.
+var aixEtcDir = "/etc"
+
+// aixRCDir returns the prefix of the rc directories of runlevels 2 and 3.
+func aixRCDir() string {
+	if _, err := os.Stat(aixEtcDir + "/rc.d/rc2.d"); err == nil {
+		return aixEtcDir + "/rc.d/rc"
 	}
-	err = run("mkssys", "-s", s.Name, "-p", path, "-u", "0", "-R", "-Q", "-S", "-n", "15", "-f", "9", "-d", "-w", "30")
+	return aixEtcDir + "/rc"
+}
+
+// mkssysArgs returns the mkssys arguments defining the subsystem.
+func (s *aixService) mkssysArgs(path string) ([]string, error) {
+	uid, _, err := lookupOwner(s.UserName, "")
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if uid < 0 {
+		uid = 0
+	}
+	args := []string{"-s", s.Name, "-p", path, "-u", strconv.Itoa(uid)}
+	if len(s.Arguments) > 0 {
+		// SRC splits the argument string of the subsystem at blanks.
+		for _, arg := range s.Arguments {
+			if arg == "" || strings.ContainsAny(arg, " \t\r\n\"'") {
+				return nil, fmt.Errorf("argument %q can not be passed by SRC, it must be non-empty without blanks or quotes", arg)
+			}
+		}
+		args = append(args, "-a", strings.Join(s.Arguments, " "))
 	}
+	return append(args, "-R", "-Q", "-S", "-n", "15", "-f", "9", "-d", "-w", "30"), nil
+}
 
-	// write start script
+func (s *aixService) Install() error {
+	if err := validateName(s.Name); err != nil {
+		return err
+	}
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
@@ -138,6 +165,21 @@ func (s *aixService) Install() error {
 		return fmt.Errorf("Init already exists: %s", confPath)
 	}
 
+	// install service
+	path, err := s.execPath()
+	if err != nil {
+		return err
+	}
+	args, err := s.mkssysArgs(path)
+	if err != nil {
+		return err
+	}
+	err = run("mkssys", args...)
+	if err != nil {
+		return err
+	}
+
+	// write start script
 	f, err := os.Create(confPath)
 	if err != nil {
 		return err
@@ -157,13 +199,10 @@ func (s *aixService) Install() error {
 		return err
 	}
 
-	if err = os.Chmod(confPath, 0755); err != nil {
+	if err = os.Chmod(confPath, fileMode(s.Option, 0755)); err != nil {
 		return err
 	}
-	rcd := "/etc/rc"
-	if _, err = os.Stat("/etc/rc.d/rc2.d"); err == nil {
-		rcd = "/etc/rc.d/rc"
-	}
+	rcd := aixRCDir()
 	for _, i := range [...]string{"2", "3"} {
 		if err = os.Symlink(confPath, rcd+i+".d/S50"+s.Name); err != nil {
 			continue
@@ -188,10 +227,23 @@ func (s *aixService) Uninstall() error {
 	if err != nil {
 		return err
 	}
+	rcd := aixRCDir()
+	for _, i := range [...]string{"2", "3"} {
+		for _, link := range []string{rcd + i + ".d/S50" + s.Name, rcd + i + ".d/K02" + s.Name} {
+			if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
 	return os.Remove(confPath)
 }
 
 func (s *aixService) Status() (Status, error) {
+	status, err := s.status()
+	return checkHealth(context.Background(), s.Option, status, err)
+}
+
+func (s *aixService) status() (Status, error) {
 	exitCode, out, err := runWithOutput("lssrc", "-s", s.Name)
 	if exitCode == 0 && err != nil {
 		if !strings.Contains(err.Error(), "failed with stderr") {
@@ -208,8 +260,7 @@ func (s *aixService) Status() (Status, error) {
 		} else if status == "active" {
 			return StatusRunning, nil
 		} else {
-			fmt.Printf("Got unknown service status %s\n", status)
-			return StatusUnknown, err
+			return StatusUnknown, fmt.Errorf("unknown lssrc status %q of %s", status, s.Name)
 		}
 	}
 
@@ -231,30 +282,48 @@ func (s *aixService) Start() error {
 func (s *aixService) Stop() error {
 	return run("stopsrc", "-s", s.Name)
 }
+
+// DisableAndStop removes the rc start links and stops the subsystem.
+func (s *aixService) DisableAndStop() error {
+	rcd := aixRCDir()
+	for _, i := range [...]string{"2", "3"} {
+		if err := os.Remove(rcd + i + ".d/S50" + s.Name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return s.Stop()
+}
+
 func (s *aixService) Restart() error {
-	err := s.Stop()
+	delay, err := restartDelay(s.Option)
+	if err != nil {
+		return err
+	}
+	err = s.Stop()
 	if err != nil {
 		return err
 	}
-	time.Sleep(50 * time.Millisecond)
+	time.Sleep(delay)
 	return s.Start()
 }
 
 func (s *aixService) Run() error {
 	var err error
 
-	err = s.i.Start(s)
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	err = callInterface(s, s.Option, s.i.Start)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		waitForStopSignal(s, s.i)
 	})()
 
-	return s.i.Stop(s)
+	return callInterface(s, s.Option, s.i.Stop)
 }
 
 func (s *aixService) Logger(errs chan<- error) (Logger, error) {
@@ -264,7 +333,7 @@ func (s *aixService) Logger(errs chan<- error) (Logger, error) {
 	return s.SystemLogger(errs)
 }
 func (s *aixService) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Option, s.Name, errs)
 }
 
 var svcConfig = `#!/bin/ksh
@@ -0,0 +1,50 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAIXDisableAndStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d string) { aixEtcDir = d }(aixEtcDir)
+	aixEtcDir = dir
+
+	var links []string
+	for _, level := range []string{"2", "3"} {
+		link := filepath.Join(dir, "rc.d", "rc"+level+".d", "S50test")
+		if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink("/etc/rc.d/init.d/test", link); err != nil {
+			t.Fatal(err)
+		}
+		links = append(links, link)
+	}
+
+	calls, restore := fakeCommandRunner(map[string]fakeResult{"stopsrc -s test": {}})
+	defer restore()
+	s := &aixService{Config: &Config{Name: "test"}}
+	if err := s.DisableAndStop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range links {
+		if _, err := os.Lstat(link); !os.IsNotExist(err) {
+			t.Errorf("DisableAndStop() left %s, err = %v", link, err)
+		}
+	}
+	if got, want := strings.Join(*calls, ","), "stopsrc -s test"; got != want {
+		t.Errorf("DisableAndStop() ran %q, want %q", got, want)
+	}
+}
@@ -5,15 +5,17 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
-	"os/signal"
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
-	"syscall"
 	"text/template"
 	"time"
 )
@@ -44,7 +46,7 @@ func (darwinSystem) New(i Interface, c *Config) (Service, error) {
 		i:      i,
 		Config: c,
 
-		userService: c.Option.bool(optionUserService, optionUserServiceDefault),
+		userService: preferUserService(c.Option),
 	}
 
 	return s, nil
@@ -69,6 +71,13 @@ func isInteractive() (bool, error) {
 	return os.Getppid() != 1, nil
 }
 
+// setProcessName sets the name reported by ps for the current process.
+// There is no equivalent of PR_SET_NAME, so only argv[0] is rewritten.
+func setProcessName(name string) error {
+	setArgv0(name)
+	return nil
+}
+
 type darwinLaunchdService struct {
 	i Interface
 	*Config
@@ -87,6 +96,107 @@ func (s *darwinLaunchdService) Platform() string {
 	return version
 }
 
+func (s *darwinLaunchdService) Capabilities() Capability {
+	return CapabilityUserService
+}
+
+// serviceTarget returns the launchctl service target of the job.
+func (s *darwinLaunchdService) serviceTarget() string {
+	if s.userService {
+		return fmt.Sprintf("gui/%d/%s", os.Getuid(), s.Name)
+	}
+	return "system/" + s.Name
+}
+
+// Properties returns the top level "key = value" lines launchctl print shows
+// for the job, nested blocks such as arguments are left out.
+func (s *darwinLaunchdService) Properties() (map[string]string, error) {
+	_, out, err := runWithOutput("launchctl", "print", s.serviceTarget())
+	if err != nil {
+		return nil, err
+	}
+	return parseLaunchdProperties(out), nil
+}
+
+func parseLaunchdProperties(out string) map[string]string {
+	props := make(map[string]string)
+	depth := 0
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasSuffix(line, "{"):
+			depth++
+		case line == "}":
+			depth--
+		case depth == 1:
+			if kv := strings.SplitN(line, " = ", 2); len(kv) == 2 {
+				props[kv[0]] = kv[1]
+			}
+		}
+	}
+	return props
+}
+
+func (s *darwinLaunchdService) LastRunResult() (*RunResult, error) {
+	_, out, err := runWithOutput("launchctl", "print", s.serviceTarget())
+	if err != nil {
+		return nil, err
+	}
+	return parseLaunchdRunResult(out)
+}
+
+// RunNow starts a calendar or interval job immediately with launchctl kickstart.
+func (s *darwinLaunchdService) RunNow() error {
+	scheduled, err := s.isScheduled()
+	if err != nil {
+		return err
+	}
+	if !scheduled {
+		return ErrNotSupported
+	}
+	return run("launchctl", s.runNowArgs()...)
+}
+
+func (s *darwinLaunchdService) runNowArgs() []string {
+	return []string{"kickstart", s.serviceTarget()}
+}
+
+// isScheduled reports if the installed job has StartCalendarInterval or StartInterval.
+func (s *darwinLaunchdService) isScheduled() (bool, error) {
+	confPath, err := s.getServiceFilePath()
+	if err != nil {
+		return false, err
+	}
+	b, err := ioutil.ReadFile(confPath)
+	if os.IsNotExist(err) {
+		return false, ErrNotInstalled
+	}
+	if err != nil {
+		return false, err
+	}
+	return bytes.Contains(b, []byte("<key>StartCalendarInterval</key>")) ||
+		bytes.Contains(b, []byte("<key>StartInterval</key>")), nil
+}
+
+var launchdLastExitRe = regexp.MustCompile(`(?m)^\s*last exit (?:code|status) = (-?[0-9]+|\(never exited\))`)
+
+// parseLaunchdRunResult reads the last exit code from launchctl print output.
+// launchd does not record when the job exited, FinishedAt is left zero.
+func parseLaunchdRunResult(out string) (*RunResult, error) {
+	matches := launchdLastExitRe.FindStringSubmatch(out)
+	if len(matches) != 2 {
+		return nil, errors.New("launchctl print output has no last exit code")
+	}
+	if matches[1] == "(never exited)" {
+		return &RunResult{}, nil
+	}
+	code, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return nil, err
+	}
+	return &RunResult{ExitCode: code, Success: code == 0}, nil
+}
+
 func (s *darwinLaunchdService) getHomeDir() (string, error) {
 	u, err := user.Current()
 	if err == nil {
@@ -153,6 +263,46 @@ func (s *darwinLaunchdService) template() *template.Template {
 }
 
 func (s *darwinLaunchdService) Install() error {
+	_, err := s.InstallWithResult()
+	return err
+}
+
+func (s *darwinLaunchdService) InstallWithResult() (InstallResult, error) {
+	result := InstallResult{UserService: s.userService}
+	return result, s.install()
+}
+
+// keepAlive returns the KeepAlive setting, and whether the job should only be
+// kept alive after an unsuccessful exit.
+func (s *darwinLaunchdService) keepAlive() (keepAlive, onFailure bool, err error) {
+	if _, set := s.Option[optionKeepAlive]; set {
+		return s.Option.bool(optionKeepAlive, optionKeepAliveDefault), false, nil
+	}
+	policy, err := restartPolicy(s.Option)
+	if err != nil {
+		return false, false, err
+	}
+	switch policy {
+	case restartPolicyNo:
+		return false, false, nil
+	case restartPolicyOnFailure:
+		return true, true, nil
+	}
+	return optionKeepAliveDefault, false, nil
+}
+
+func (s *darwinLaunchdService) install() error {
+	keepAlive, keepAliveOnFailure, err := s.keepAlive()
+	if err != nil {
+		return err
+	}
+
+	groupName := s.Option.string(optionGroupName, "")
+	uid, gid, err := lookupOwner(s.UserName, groupName)
+	if err != nil {
+		return err
+	}
+
 	confPath, err := s.getServiceFilePath()
 	if err != nil {
 		return err
@@ -176,6 +326,10 @@ func (s *darwinLaunchdService) Install() error {
 	}
 	defer f.Close()
 
+	if err = f.Chmod(fileMode(s.Option, 0644)); err != nil {
+		return err
+	}
+
 	path, err := s.execPath()
 	if err != nil {
 		return err
@@ -186,21 +340,48 @@ func (s *darwinLaunchdService) Install() error {
 		*Config
 		Path string
 
+		GroupName            string
 		KeepAlive, RunAtLoad bool
+		KeepAliveOnFailure   bool
 		SessionCreate        bool
 		StandardOutPath      string
 		StandardErrorPath    string
 	}{
-		Config:            s.Config,
-		Path:              path,
-		KeepAlive:         s.Option.bool(optionKeepAlive, optionKeepAliveDefault),
-		RunAtLoad:         s.Option.bool(optionRunAtLoad, optionRunAtLoadDefault),
-		SessionCreate:     s.Option.bool(optionSessionCreate, optionSessionCreateDefault),
-		StandardOutPath:   stdOutPath,
-		StandardErrorPath: stdErrPath,
+		Config:             s.Config,
+		Path:               path,
+		GroupName:          groupName,
+		KeepAlive:          keepAlive,
+		KeepAliveOnFailure: keepAliveOnFailure,
+		RunAtLoad:          s.Option.bool(optionRunAtLoad, optionRunAtLoadDefault),
+		SessionCreate:      s.Option.bool(optionSessionCreate, optionSessionCreateDefault),
+		StandardOutPath:    stdOutPath,
+		StandardErrorPath:  stdErrPath,
 	}
 
-	return s.template().Execute(f, to)
+	err = s.template().Execute(f, to)
+	if err != nil {
+		return err
+	}
+
+	if uid == -1 && gid == -1 {
+		return nil
+	}
+	return s.chownLogs(uid, gid, stdOutPath, stdErrPath)
+}
+
+// chownLogs gives the service user and group ownership of its log files.
+// A custom log directory is owned by them as well, the default one is shared
+// and left alone.
+func (s *darwinLaunchdService) chownLogs(uid, gid int, stdOutPath, stdErrPath string) error {
+	if dir := s.Option.string(optionLogDirectory, ""); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := os.Chown(dir, uid, gid); err != nil {
+			return err
+		}
+	}
+	return chownLogFiles(uid, gid, stdOutPath, stdErrPath)
 }
 
 func (s *darwinLaunchdService) Uninstall() error {
@@ -214,6 +395,23 @@ func (s *darwinLaunchdService) Uninstall() error {
 }
 
 func (s *darwinLaunchdService) Status() (Status, error) {
+	status, err := s.status()
+	return checkHealth(context.Background(), s.Option, status, err)
+}
+
+// CombinedStatus adds the pid launchctl print shows to Status.
+func (s *darwinLaunchdService) CombinedStatus() ServiceStatus {
+	state, err := s.Status()
+	st := ServiceStatus{State: state, LastError: err}
+	if state == StatusRunning || state == StatusDegraded {
+		if props, err := s.Properties(); err == nil {
+			st.PID, _ = strconv.Atoi(props["pid"])
+		}
+	}
+	return st
+}
+
+func (s *darwinLaunchdService) status() (Status, error) {
 	exitCode, out, err := runWithOutput("launchctl", "list", s.Name)
 	if exitCode == 0 && err != nil {
 		if !strings.Contains(err.Error(), "failed with stderr") {
@@ -255,28 +453,67 @@ func (s *darwinLaunchdService) Stop() error {
 	return run("launchctl", "unload", confPath)
 }
 
+// DisableAndStop sets the disabled override of the job, so it is not loaded
+// at boot or login, and removes it from launchd.
+func (s *darwinLaunchdService) DisableAndStop() error {
+	if err := run("launchctl", "disable", s.serviceTarget()); err != nil {
+		return err
+	}
+	return run("launchctl", "bootout", s.serviceTarget())
+}
+
 func (s *darwinLaunchdService) Restart() error {
-	err := s.Stop()
+	delay, err := restartDelay(s.Option)
 	if err != nil {
 		return err
 	}
-	time.Sleep(50 * time.Millisecond)
+	err = s.Stop()
+	if err != nil {
+		return err
+	}
+	time.Sleep(delay)
 	return s.Start()
 }
 
 func (s *darwinLaunchdService) Run() error {
-	err := s.i.Start(s)
+	if name := s.Option.string(optionProcessName, ""); name != "" {
+		if err := setProcessName(name); err != nil {
+			return err
+		}
+	}
+
+	if target := s.Option.string(optionWaitForService, ""); target != "" {
+		timeout := s.Option.duration(optionWaitForServiceTimeout, optionWaitForServiceTimeoutDefault)
+		if !waitUntil(timeout, 500*time.Millisecond, func() bool { return isAvailable(target) }) {
+			return fmt.Errorf("timed out after %v waiting for %s", timeout, target)
+		}
+	}
+
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	err := callInterface(s, s.Option, s.i.Start)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		waitForStopSignal(s, s.i)
 	})()
 
-	return s.i.Stop(s)
+	return callInterface(s, s.Option, s.i.Stop)
+}
+
+// isAvailable reports whether target exists, where target is either an
+// absolute file path or the label of a loaded launchd job.
+func isAvailable(target string) bool {
+	if filepath.IsAbs(target) {
+		_, err := os.Stat(target)
+		return err == nil
+	}
+	_, _, err := runWithOutput("launchctl", "list", target)
+	return err == nil
 }
 
 func (s *darwinLaunchdService) Logger(errs chan<- error) (Logger, error) {
@@ -287,7 +524,7 @@ func (s *darwinLaunchdService) Logger(errs chan<- error) (Logger, error) {
 }
 
 func (s *darwinLaunchdService) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Option, s.Name, errs)
 }
 
 var launchdConfig = `<?xml version="1.0" encoding="UTF-8"?>
@@ -305,8 +542,19 @@ var launchdConfig = `<?xml version="1.0" encoding="UTF-8"?>
 		{{- end}}
 	</dict>
 	{{- end}}
+	{{- if .GroupName}}
+	<key>GroupName</key>
+	<string>{{html .GroupName}}</string>
+	{{- end}}
 	<key>KeepAlive</key>
+	{{- if .KeepAliveOnFailure}}
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	{{- else}}
 	<{{bool .KeepAlive}}/>
+	{{- end}}
 	<key>Label</key>
 	<string>{{html .Name}}</string>
 	<key>ProgramArguments</key>
@@ -0,0 +1,146 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLaunchdGroupName(t *testing.T) {
+	s := &darwinLaunchdService{Config: &Config{Name: "test"}}
+	for _, groupName := range []string{"", "_test"} {
+		var b bytes.Buffer
+		err := s.template().Execute(&b, struct {
+			*Config
+			Path                 string
+			GroupName            string
+			KeepAlive, RunAtLoad bool
+			SessionCreate        bool
+			StandardOutPath      string
+			StandardErrorPath    string
+		}{Config: s.Config, Path: "/usr/local/bin/test", GroupName: groupName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "<key>GroupName</key>\n\t<string>" + groupName + "</string>"
+		if got := strings.Contains(b.String(), want); got != (groupName != "") {
+			t.Errorf("GroupName %q: plist contains GroupName key = %v\n%s", groupName, got, b.String())
+		}
+	}
+}
+
+func TestLaunchdRestartPolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		option        KeyValue
+		wantKeepAlive bool
+		wantOnFailure bool
+	}{
+		{"default", nil, true, false},
+		{"always", KeyValue{optionRestartPolicy: restartPolicyAlways}, true, false},
+		{"on-failure", KeyValue{optionRestartPolicy: restartPolicyOnFailure}, true, true},
+		{"no", KeyValue{optionRestartPolicy: restartPolicyNo}, false, false},
+		{"keepalive-overrides", KeyValue{optionRestartPolicy: restartPolicyNo, optionKeepAlive: true}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &darwinLaunchdService{Config: &Config{Name: "test", Option: tt.option}}
+			keepAlive, onFailure, err := s.keepAlive()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if keepAlive != tt.wantKeepAlive || onFailure != tt.wantOnFailure {
+				t.Errorf("keepAlive() = %v, %v, want %v, %v", keepAlive, onFailure, tt.wantKeepAlive, tt.wantOnFailure)
+			}
+		})
+	}
+}
+
+func TestParseLaunchdRunResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    RunResult
+		wantErr bool
+	}{
+		{"success", "system/test = {\n\tstate = not running\n\tlast exit code = 0\n}\n", RunResult{ExitCode: 0, Success: true}, false},
+		{"failure", "system/test = {\n\tlast exit code = 78: EX_CONFIG\n}\n", RunResult{ExitCode: 78}, false},
+		{"never exited", "system/test = {\n\tlast exit code = (never exited)\n}\n", RunResult{}, false},
+		{"missing", "system/test = {\n}\n", RunResult{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLaunchdRunResult(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLaunchdRunResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("parseLaunchdRunResult() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLaunchdRunNowArgs(t *testing.T) {
+	s := &darwinLaunchdService{Config: &Config{Name: "com.example.test"}}
+	if got, want := strings.Join(s.runNowArgs(), " "), "kickstart system/com.example.test"; got != want {
+		t.Errorf("runNowArgs() = %q, want %q", got, want)
+	}
+	s.userService = true
+	if got := strings.Join(s.runNowArgs(), " "); !strings.HasPrefix(got, "kickstart gui/") || !strings.HasSuffix(got, "/com.example.test") {
+		t.Errorf("runNowArgs() = %q, want a gui/<uid> target", got)
+	}
+}
+
+func TestLaunchdDisableAndStop(t *testing.T) {
+	calls, restore := fakeCommandRunner(map[string]fakeResult{
+		"launchctl disable system/test": {},
+		"launchctl bootout system/test": {},
+	})
+	defer restore()
+
+	s := &darwinLaunchdService{Config: &Config{Name: "test"}}
+	if err := s.DisableAndStop(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(*calls, ","), "launchctl disable system/test,launchctl bootout system/test"; got != want {
+		t.Errorf("DisableAndStop() ran %q, want %q", got, want)
+	}
+}
+
+func TestParseLaunchdProperties(t *testing.T) {
+	const out = `system/test = {
+	active count = 1
+	path = /Library/LaunchDaemons/test.plist
+	state = running
+	program = /usr/local/bin/test
+	arguments = {
+		/usr/local/bin/test
+		-v
+	}
+	pid = 321
+	last exit code = 0
+}
+`
+	got := parseLaunchdProperties(out)
+	want := map[string]string{
+		"active count":   "1",
+		"path":           "/Library/LaunchDaemons/test.plist",
+		"state":          "running",
+		"program":        "/usr/local/bin/test",
+		"pid":            "321",
+		"last exit code": "0",
+	}
+	if len(got) != len(want) {
+		t.Errorf("parseLaunchdProperties() has %d properties, want %d: %q", len(got), len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
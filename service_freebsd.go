@@ -5,11 +5,11 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/signal"
 	"path/filepath"
-	"syscall"
+	"strings"
 	"text/template"
 )
 
@@ -98,7 +98,18 @@ func (s *freebsdService) configPath() (cp string, err error) {
 	return
 }
 
+// rcvar returns the rc.conf variable enabling the service, the name with
+// every '-' replaced as a shell variable can not contain it.
+func (s *freebsdService) rcvar() string {
+	return strings.Replace(s.Name, "-", "_", -1) + "_enable"
+}
+
 func (s *freebsdService) Install() error {
+	policy, err := restartPolicy(s.Option)
+	if err != nil {
+		return err
+	}
+
 	path, err := s.execPath()
 	if err != nil {
 		return err
@@ -122,10 +133,16 @@ func (s *freebsdService) Install() error {
 
 	var to = &struct {
 		*Config
-		Path string
+		Path               string
+		RCVar              string
+		Respawn            bool
+		AfterNetworkOnline bool
 	}{
 		s.Config,
 		path,
+		s.rcvar(),
+		policy != restartPolicyNo,
+		s.Option.bool(optionAfterNetworkOnline, optionAfterNetworkOnlineDefault),
 	}
 
 	err = s.template().Execute(f, to)
@@ -133,10 +150,13 @@ func (s *freebsdService) Install() error {
 		return err
 	}
 
-	if err = os.Chmod(confPath, 0755); err != nil {
+	if err = os.Chmod(confPath, fileMode(s.Option, 0755)); err != nil {
 		return err
 	}
 
+	if s.Option.bool(optionEnabled, optionEnabledDefault) {
+		return run("sysrc", s.rcvar()+"=YES")
+	}
 	return nil
 }
 
@@ -145,10 +165,21 @@ func (s *freebsdService) Uninstall() error {
 	if err != nil {
 		return err
 	}
-	return os.Remove(cp)
+	if err := os.Remove(cp); err != nil {
+		return err
+	}
+	// The rcvar is only set if the service was enabled, a failure to remove
+	// an unset variable does not matter.
+	run("sysrc", "-x", s.rcvar())
+	return nil
 }
 
 func (s *freebsdService) Status() (Status, error) {
+	status, err := s.status()
+	return checkHealth(context.Background(), s.Option, status, err)
+}
+
+func (s *freebsdService) status() (Status, error) {
 	cp, err := s.configPath()
 	if err != nil {
 		return StatusUnknown, err
@@ -158,7 +189,8 @@ func (s *freebsdService) Status() (Status, error) {
 		return StatusStopped, ErrNotInstalled
 	}
 
-	status, _, err := runCommand("service", false, s.Name, "status")
+	// The one prefix of rc.subr acts whether or not the rcvar is set.
+	status, _, err := runCommand("service", false, s.Name, "onestatus")
 	if status == 1 {
 		return StatusStopped, nil
 	} else if err != nil {
@@ -168,32 +200,43 @@ func (s *freebsdService) Status() (Status, error) {
 }
 
 func (s *freebsdService) Start() error {
-	return run("service", s.Name, "start")
+	return run("service", s.Name, "onestart")
 }
 
 func (s *freebsdService) Stop() error {
-	return run("service", s.Name, "stop")
+	return run("service", s.Name, "onestop")
+}
+
+// DisableAndStop sets the rcvar to NO so the service does not start at boot
+// and stops it.
+func (s *freebsdService) DisableAndStop() error {
+	if err := run("sysrc", s.rcvar()+"=NO"); err != nil {
+		return err
+	}
+	return s.Stop()
 }
 
 func (s *freebsdService) Restart() error {
-	return run("service", s.Name, "restart")
+	return run("service", s.Name, "onerestart")
 }
 
 func (s *freebsdService) Run() error {
 	var err error
 
-	err = s.i.Start(s)
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	err = callInterface(s, s.Option, s.i.Start)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		waitForStopSignal(s, s.i)
 	})()
 
-	return s.i.Stop(s)
+	return callInterface(s, s.Option, s.i.Stop)
 }
 
 func (s *freebsdService) Logger(errs chan<- error) (Logger, error) {
@@ -204,22 +247,27 @@ func (s *freebsdService) Logger(errs chan<- error) (Logger, error) {
 }
 
 func (s *freebsdService) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Option, s.Name, errs)
 }
 
 var rcScript = `#!/bin/sh
 
 # PROVIDE: {{.Name}}
-# REQUIRE: SERVERS
+# REQUIRE: SERVERS{{if .AfterNetworkOnline}} NETWORKING{{end}}
 # KEYWORD: shutdown
 
 . /etc/rc.subr
 
 name="{{.Name}}"
+rcvar="{{.RCVar}}"
+
+load_rc_config "$name"
+: "${ {{- .RCVar}}:=NO}"
+
 {{.Name}}_env="IS_DAEMON=1"
 pidfile="/var/run/${name}.pid"
 command="/usr/sbin/daemon"
-daemon_args="-P ${pidfile} -r -t \"${name}: daemon\"{{if .WorkingDirectory}} -c {{.WorkingDirectory}}{{end}}"
+daemon_args="-P ${pidfile}{{if .Respawn}} -r{{end}} -t \"${name}: daemon\"{{if .WorkingDirectory}} -c {{.WorkingDirectory}}{{end}}"
 command_args="${daemon_args} {{.Path}}{{range .Arguments}} {{.}}{{end}}"
 
 run_rc_command "$1"
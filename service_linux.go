@@ -9,7 +9,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"syscall"
+	"unsafe"
 )
 
 var cgroupFile = "/proc/1/cgroup"
@@ -53,6 +58,24 @@ func init() {
 			},
 			new: newUpstartService,
 		},
+		linuxSystemService{
+			name:   "linux-runit",
+			detect: isRunit,
+			interactive: func() bool {
+				is, _ := isInteractive()
+				return is
+			},
+			new: newRunitService,
+		},
+		linuxSystemService{
+			name:   "linux-s6",
+			detect: isS6,
+			interactive: func() bool {
+				is, _ := isInteractive()
+				return is
+			},
+			new: newS6Service,
+		},
 		linuxSystemService{
 			name:   "linux-openrc",
 			detect: isOpenRC,
@@ -142,11 +165,97 @@ func isInContainer(cgroupPath string) (bool, error) {
 	return false, nil
 }
 
+// setProcessName sets the name reported by ps and top for the current process.
+func setProcessName(name string) error {
+	comm := append([]byte(name), 0)
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, syscall.PR_SET_NAME, uintptr(unsafe.Pointer(&comm[0])), 0)
+	if errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_NAME) failed: %v", errno)
+	}
+	// PR_SET_NAME only renames the calling thread, which is not necessarily
+	// the thread group leader that ps reports.
+	if syscall.Gettid() != os.Getpid() {
+		if err := ioutil.WriteFile("/proc/self/comm", []byte(name), 0644); err != nil {
+			return err
+		}
+	}
+	setArgv0(name)
+	return nil
+}
+
+// lockPIDFile takes an exclusive lock on the pid file at path and writes the
+// current pid to it. ErrAlreadyRunning is returned if the lock is held elsewhere.
+func lockPIDFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, ErrAlreadyRunning
+		}
+		return nil, fmt.Errorf("flock %s failed: %v", path, err)
+	}
+	if err := f.Truncate(0); err != nil {
+		unlockPIDFile(f)
+		return nil, err
+	}
+	if _, err := f.WriteString(strconv.Itoa(os.Getpid()) + "\n"); err != nil {
+		unlockPIDFile(f)
+		return nil, err
+	}
+	return f, nil
+}
+
+// unlockPIDFile releases the lock taken by lockPIDFile.
+func unlockPIDFile(f *os.File) error {
+	defer f.Close()
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
 var tf = map[string]interface{}{
+	// cmd quotes s as a single POSIX shell word. Newlines and tabs are kept
+	// as they are, the quoted word then spans several lines of the script.
 	"cmd": func(s string) string {
-		return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+		return `'` + strings.Replace(s, `'`, `'\''`, -1) + `'`
 	},
 	"cmdEscape": func(s string) string {
 		return strings.Replace(s, " ", `\x20`, -1)
 	},
+	"join": strings.Join,
+	// cmdExpand quotes s as a single POSIX shell word in which the $NAME and
+	// ${NAME} environment references still expand. Any other $ is literal.
+	// Newlines and tabs are kept like cmd.
+	"cmdExpand": func(s string) string {
+		var b strings.Builder
+		b.WriteByte('"')
+		for i := 0; i < len(s); i++ {
+			switch c := s[i]; c {
+			case '"', '\\', '`':
+				b.WriteByte('\\')
+				b.WriteByte(c)
+			case '$':
+				if !envRefRe.MatchString(s[i:]) {
+					b.WriteByte('\\')
+				}
+				b.WriteByte(c)
+			default:
+				b.WriteByte(c)
+			}
+		}
+		b.WriteByte('"')
+		return b.String()
+	},
+}
+
+var envRefRe = regexp.MustCompile(`^\$([A-Za-z_]|\{[A-Za-z_][A-Za-z0-9_]*\})`)
+
+// initDir returns the directory System V and rcS init scripts are installed to.
+func initDir(kv KeyValue) (string, error) {
+	dir := kv.string(optionInitDir, optionInitDirDefault)
+	if !filepath.IsAbs(dir) {
+		return "", fmt.Errorf("%s must be an absolute path, got %q", optionInitDir, dir)
+	}
+	return filepath.Clean(dir), nil
 }
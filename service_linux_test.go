@@ -5,10 +5,15 @@
 package service
 
 import (
+	"bytes"
 	"errors"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"reflect"
+	"strings"
 	"testing"
+	"text/template"
 )
 
 // createTestCgroupFiles creates mock files for tests
@@ -171,3 +176,139 @@ const (
 1:name=systemd:/init.scope
 0::/init.scope`
 )
+
+func Test_setProcessName(t *testing.T) {
+	const name = "svc-name-test"
+	// setProcessName rewrites argv in place, so it runs in a child process.
+	if os.Getenv("SERVICE_TEST_PROCESS_NAME") == "1" {
+		if err := setProcessName(name); err != nil {
+			t.Fatal(err)
+		}
+		comm, err := ioutil.ReadFile("/proc/self/comm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(string(comm)); got != name {
+			t.Errorf("comm = %q, want %q", got, name)
+		}
+		if os.Args[0] != name {
+			t.Errorf("os.Args[0] = %q, want %q", os.Args[0], name)
+		}
+		return
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe, "-test.run=^Test_setProcessName$")
+	cmd.Env = append(os.Environ(), "SERVICE_TEST_PROCESS_NAME=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("child process failed: %v\n%s", err, out)
+	}
+}
+
+func Test_tfCmd(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"plain", []string{"-v", "run"}},
+		{"spaces", []string{"hello world", "  padded  "}},
+		{"quotes", []string{`it's`, `say "hi"`, `'`, `''`}},
+		{"dollar", []string{"$HOME", "${PATH}", "$(id)", "`id`"}},
+		{"empty", []string{""}},
+		{"newline", []string{"{\n  \"key\": \"value\"\n}", "a\tb", "trailing\n"}},
+	}
+	tmpl := template.Must(template.New("").Funcs(tf).Parse(
+		`printf '%s\0'{{range .}} {{.|cmd}}{{end}}`))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var script bytes.Buffer
+			if err := tmpl.Execute(&script, tt.args); err != nil {
+				t.Fatal(err)
+			}
+			out, err := exec.Command("sh", "-c", script.String()).Output()
+			if err != nil {
+				t.Fatalf("sh -c %q: %v", script.String(), err)
+			}
+			got := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+			if !reflect.DeepEqual(got, tt.args) {
+				t.Errorf("argv = %q, want %q", got, tt.args)
+			}
+		})
+	}
+}
+
+// The platform names are documented on Platform and compared against by
+// programs, they must not change.
+func TestLinuxPlatformNames(t *testing.T) {
+	var got []string
+	for _, s := range AvailableSystems() {
+		got = append(got, s.String())
+	}
+	want := []string{"linux-systemd", "linux-upstart", "linux-runit", "linux-s6", "linux-openrc", "linux-rcs", "unix-systemv"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AvailableSystems() = %q, want %q", got, want)
+	}
+	if s := ChosenSystem(); s == nil || s.String() != Platform() {
+		t.Errorf("ChosenSystem() = %v, want the system named %q", s, Platform())
+	}
+}
+
+func TestNewSystemOption(t *testing.T) {
+	// System is used even if no system or another one is detected.
+	defer func(s System) { system = s }(system)
+	system = nil
+
+	s, err := New(nil, &Config{Name: "test", Option: KeyValue{optionSystem: "unix-systemv"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*sysv); !ok || s.Platform() != "unix-systemv" {
+		t.Errorf("New() with System unix-systemv = %T on %q", s, s.Platform())
+	}
+	if s, err := New(nil, &Config{Name: "test", Option: KeyValue{optionSystem: "linux-systemd"}}); err != nil || s.Platform() != "linux-systemd" {
+		t.Errorf("New() with System linux-systemd = %v, %v", s, err)
+	}
+
+	_, err = New(nil, &Config{Name: "test", Option: KeyValue{optionSystem: "launchd"}})
+	if err == nil || !strings.Contains(err.Error(), "unix-systemv") {
+		t.Errorf("New() with an unknown System = %v, want an error listing the systems", err)
+	}
+	if _, err := New(nil, &Config{Name: "test"}); err != ErrNoServiceSystemDetected {
+		t.Errorf("New() without System = %v, want %v", err, ErrNoServiceSystemDetected)
+	}
+}
+
+// Unsupported modes unwrap to ErrNotSupported on every Linux backend.
+func TestLinuxNotSupported(t *testing.T) {
+	c := &Config{Name: "test", Option: KeyValue{optionUserService: true}}
+	for _, s := range []interface{ Install() error }{
+		&sysv{Config: c},
+		&openrc{Config: c},
+		&rcs{Config: c},
+		&upstart{Config: c},
+	} {
+		err := s.Install()
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok || u.Unwrap() != ErrNotSupported || !strings.Contains(err.Error(), "user service is not supported on") {
+			t.Errorf("%T Install() of a user service = %v, want an error wrapping %v", s, err, ErrNotSupported)
+		}
+	}
+}
+
+// A multi-line argument stays one word of the init script.
+func Test_tfCmdExpandNewline(t *testing.T) {
+	arg := "{\n\t\"home\": \"$HOME\"\n}"
+	expand := tf["cmdExpand"].(func(string) string)
+	cmd := exec.Command("sh", "-c", `printf '%s\0' `+expand(arg))
+	cmd.Env = []string{"HOME=/home/app"}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), "{\n\t\"home\": \"/home/app\"\n}\x00"; got != want {
+		t.Errorf("argv = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2019 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const version = "openbsd"
+const configDir = "/etc/rc.d"
+
+type openbsdSystem struct{}
+
+func (openbsdSystem) String() string {
+	return version
+}
+func (openbsdSystem) Detect() bool {
+	return true
+}
+func (openbsdSystem) Interactive() bool {
+	return interactive
+}
+func (openbsdSystem) New(i Interface, c *Config) (Service, error) {
+	s := &openbsdService{
+		i:      i,
+		Config: c,
+	}
+
+	return s, nil
+}
+
+func init() {
+	ChooseSystem(openbsdSystem{})
+}
+
+var interactive = false
+
+func init() {
+	var err error
+	interactive, err = isInteractive()
+	if err != nil {
+		panic(err)
+	}
+}
+
+func isInteractive() (bool, error) {
+	return os.Getenv("IS_DAEMON") != "1", nil
+}
+
+type openbsdService struct {
+	i Interface
+	*Config
+}
+
+func (s *openbsdService) String() string {
+	if len(s.DisplayName) > 0 {
+		return s.DisplayName
+	}
+	return s.Name
+}
+
+func (s *openbsdService) Platform() string {
+	return version
+}
+
+// openbsdNameRe matches the daemon names rc.subr and rcctl accept, which
+// become part of the <name>_flags variables of rc.conf.local.
+var openbsdNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// openbsdQuote quotes s as a single shell word.
+func openbsdQuote(s string) string {
+	return `'` + strings.Replace(s, `'`, `'\''`, -1) + `'`
+}
+
+func (s *openbsdService) template() *template.Template {
+	functions := template.FuncMap{
+		"cmd": openbsdQuote,
+		// flags quotes each argument and the list as a whole, rc.subr puts
+		// daemon_flags unquoted into the command line it runs.
+		"flags": func(args []string) string {
+			quoted := make([]string, len(args))
+			for i, arg := range args {
+				quoted[i] = openbsdQuote(arg)
+			}
+			return openbsdQuote(strings.Join(quoted, " "))
+		},
+	}
+
+	customConfig := s.Option.string(optionSysvScript, "")
+
+	if customConfig != "" {
+		return template.Must(template.New("").Funcs(functions).Parse(customConfig))
+	}
+	return template.Must(template.New("").Funcs(functions).Parse(openbsdScript))
+}
+
+func (s *openbsdService) configPath() (string, error) {
+	if !openbsdNameRe.MatchString(s.Name) {
+		return "", fmt.Errorf("invalid service name %q: rc.d only allows letters, digits and '_' not leading with a digit", s.Name)
+	}
+	return filepath.Join(configDir, s.Config.Name), nil
+}
+
+func (s *openbsdService) Install() error {
+	path, err := s.execPath()
+	if err != nil {
+		return err
+	}
+
+	confPath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(confPath)
+	if err == nil {
+		return fmt.Errorf("Init already exists: %s", confPath)
+	}
+
+	f, err := os.Create(confPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var to = &struct {
+		*Config
+		Path string
+	}{
+		s.Config,
+		path,
+	}
+
+	err = s.template().Execute(f, to)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Chmod(confPath, fileMode(s.Option, 0755)); err != nil {
+		return err
+	}
+
+	if s.Option.bool(optionEnabled, optionEnabledDefault) {
+		return run("rcctl", "enable", s.Name)
+	}
+	return nil
+}
+
+func (s *openbsdService) Uninstall() error {
+	cp, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	// Removes the service from pkg_scripts in rc.conf.local, a failure for a
+	// service that was not enabled does not matter.
+	run("rcctl", "disable", s.Name)
+	return os.Remove(cp)
+}
+
+func (s *openbsdService) Status() (Status, error) {
+	status, err := s.status()
+	return checkHealth(context.Background(), s.Option, status, err)
+}
+
+func (s *openbsdService) status() (Status, error) {
+	cp, err := s.configPath()
+	if err != nil {
+		return StatusUnknown, err
+	}
+
+	if _, err = os.Stat(cp); os.IsNotExist(err) {
+		return StatusStopped, ErrNotInstalled
+	}
+
+	// rcctl check prints "<name>(ok)" and exits 0 if the daemon runs, or
+	// prints "<name>(failed)" and exits 1.
+	status, _, err := runCommand("rcctl", false, "check", s.Name)
+	if status == 1 {
+		return StatusStopped, nil
+	} else if err != nil {
+		return StatusUnknown, err
+	}
+	return StatusRunning, nil
+}
+
+// Start starts the service even if it is not enabled.
+func (s *openbsdService) Start() error {
+	return run("rcctl", "-f", "start", s.Name)
+}
+
+func (s *openbsdService) Stop() error {
+	return run("rcctl", "stop", s.Name)
+}
+
+// DisableAndStop removes the service from pkg_scripts and stops it.
+func (s *openbsdService) DisableAndStop() error {
+	if err := run("rcctl", "disable", s.Name); err != nil {
+		return err
+	}
+	return s.Stop()
+}
+
+func (s *openbsdService) Restart() error {
+	return run("rcctl", "-f", "restart", s.Name)
+}
+
+func (s *openbsdService) Run() error {
+	var err error
+
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	err = callInterface(s, s.Option, s.i.Start)
+	if err != nil {
+		return err
+	}
+
+	s.Option.funcSingle(optionRunWait, func() {
+		waitForStopSignal(s, s.i)
+	})()
+
+	return callInterface(s, s.Option, s.i.Stop)
+}
+
+func (s *openbsdService) Logger(errs chan<- error) (Logger, error) {
+	if interactive {
+		return ConsoleLogger, nil
+	}
+	return s.SystemLogger(errs)
+}
+
+func (s *openbsdService) SystemLogger(errs chan<- error) (Logger, error) {
+	return newSysLogger(s.Option, s.Name, errs)
+}
+
+// rc.subr matches the running daemon against daemon and daemon_flags with
+// their quotes removed, which are the arguments it was started with.
+var openbsdScript = `#!/bin/ksh
+
+daemon={{.Path|cmd}}
+daemon_flags={{.Arguments|flags}}
+{{- if .UserName}}
+daemon_user={{.UserName|cmd}}
+{{- end}}
+
+. /etc/rc.d/rc.subr
+
+rc_bg=YES
+rc_reload=NO
+
+rc_start() {
+	rc_exec "{{if .WorkingDirectory}}cd {{.WorkingDirectory|cmd}} && {{end}}IS_DAEMON=1 ${daemon} ${daemon_flags}"
+}
+
+rc_cmd $1
+`
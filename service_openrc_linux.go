@@ -2,20 +2,32 @@ package service
 
 import (
 	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
-	"os/signal"
 	"regexp"
-	"syscall"
 	"text/template"
 	"time"
 )
 
+// openRCDetectEnv overrides OpenRC detection when set to "1" (always
+// detected) or "0" (never detected).
+const openRCDetectEnv = "SERVICE_DETECT_OPENRC"
+
 func isOpenRC() bool {
-	if _, err := exec.LookPath("openrc-init"); err == nil {
+	switch os.Getenv(openRCDetectEnv) {
+	case "1":
 		return true
+	case "0":
+		return false
+	}
+	// Prefer OpenRC over plain SysV when its tools are present, even if
+	// /etc/init.d is shared with SysV style scripts.
+	for _, name := range []string{"openrc-init", "openrc", "rc-status"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return true
+		}
 	}
 	if _, err := os.Stat("/etc/inittab"); err == nil {
 		filerc, err := os.Open("/etc/inittab")
@@ -73,7 +85,7 @@ func newOpenRCService(i Interface, platform string, c *Config) (Service, error)
 	return s, nil
 }
 
-var errNoUserServiceOpenRC = errors.New("user services are not supported on OpenRC")
+var errNoUserServiceOpenRC = notSupported("user service", "OpenRC")
 
 func (s *openrc) configPath() (cp string, err error) {
 	if s.Option.bool(optionUserService, optionUserServiceDefault) {
@@ -100,7 +112,7 @@ func (s *openrc) Install() error {
 	}
 	defer f.Close()
 
-	err = os.Chmod(confPath, 0755)
+	err = os.Chmod(confPath, fileMode(s.Option, 0755))
 	if err != nil {
 		return err
 	}
@@ -112,12 +124,14 @@ func (s *openrc) Install() error {
 
 	var to = &struct {
 		*Config
-		Path         string
-		LogDirectory string
+		Path               string
+		LogDirectory       string
+		AfterNetworkOnline bool
 	}{
 		s.Config,
 		path,
 		s.Option.string(optionLogDirectory, defaultLogDirectory),
+		s.Option.bool(optionAfterNetworkOnline, optionAfterNetworkOnlineDefault),
 	}
 
 	err = s.template().Execute(f, to)
@@ -147,25 +161,38 @@ func (s *openrc) Logger(errs chan<- error) (Logger, error) {
 }
 
 func (s *openrc) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Option, s.Name, errs)
 }
 
 func (s *openrc) Run() (err error) {
-	err = s.i.Start(s)
+	if name := s.Option.string(optionProcessName, ""); name != "" {
+		if err = setProcessName(name); err != nil {
+			return err
+		}
+	}
+
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	err = callInterface(s, s.Option, s.i.Start)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		waitForStopSignal(s, s.i)
 	})()
 
-	return s.i.Stop(s)
+	return callInterface(s, s.Option, s.i.Stop)
 }
 
 func (s *openrc) Status() (Status, error) {
+	status, err := s.status()
+	return checkHealth(context.Background(), s.Option, status, err)
+}
+
+func (s *openrc) status() (Status, error) {
 	// rc-service uses the errno library for its exit codes:
 	// errno 0 = service started
 	// errno 1 = EPERM 1 Operation not permitted
@@ -174,9 +201,9 @@ func (s *openrc) Status() (Status, error) {
 	// for more info, see https://man7.org/linux/man-pages/man3/errno.3.html
 	_, out, err := runWithOutput("rc-service", s.Name, "status")
 	if err != nil {
-		if exiterr, ok := err.(*exec.ExitError); ok {
+		if cmdErr, ok := err.(*CommandError); ok && cmdErr.ExitCode != 0 {
 			// The program has exited with an exit code != 0
-			exitCode := exiterr.ExitCode()
+			exitCode := cmdErr.ExitCode
 			switch {
 			case exitCode == 1:
 				return StatusUnknown, err
@@ -202,12 +229,24 @@ func (s *openrc) Stop() error {
 	return run("rc-service", s.Name, "stop")
 }
 
+// DisableAndStop removes the service from its runlevels and stops it.
+func (s *openrc) DisableAndStop() error {
+	if err := s.runAction("del"); err != nil {
+		return err
+	}
+	return s.Stop()
+}
+
 func (s *openrc) Restart() error {
-	err := s.Stop()
+	delay, err := restartDelay(s.Option)
+	if err != nil {
+		return err
+	}
+	err = s.Stop()
 	if err != nil {
 		return err
 	}
-	time.Sleep(50 * time.Millisecond)
+	time.Sleep(delay)
 	return s.Start()
 }
 
@@ -234,8 +273,10 @@ supervise_daemon_args="--stdout {{.LogDirectory}}/${name}.log --stderr {{.LogDir
 export {{$k}}={{$v}}
 {{end -}}
 
-{{- if .Dependencies }}
+{{- if or .Dependencies .AfterNetworkOnline }}
 depend() {
+{{- if .AfterNetworkOnline}}
+{{"\t"}}need net{{end}}
 {{- range $i, $dep := .Dependencies}} 
 {{"\t"}}{{$dep}}{{end}}
 }
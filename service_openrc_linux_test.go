@@ -0,0 +1,47 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func Test_isOpenRCEnvOverride(t *testing.T) {
+	defer os.Setenv(openRCDetectEnv, os.Getenv(openRCDetectEnv))
+
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"1", true},
+		{"0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			os.Setenv(openRCDetectEnv, tt.value)
+			if got := isOpenRC(); got != tt.want {
+				t.Errorf("isOpenRC() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenRCDisableAndStop(t *testing.T) {
+	calls, restore := fakeCommandRunner(map[string]fakeResult{
+		"rc-update del test":   {},
+		"rc-service test stop": {},
+	})
+	defer restore()
+
+	s := &openrc{Config: &Config{Name: "test"}}
+	if err := s.DisableAndStop(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(*calls, ","), "rc-update del test,rc-service test stop"; got != want {
+		t.Errorf("DisableAndStop() ran %q, want %q", got, want)
+	}
+}
@@ -6,14 +6,13 @@ package service
 
 import (
 	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
-	"os/signal"
+	"path/filepath"
 	"regexp"
 	"strings"
-	"syscall"
 	"text/template"
 	"time"
 )
@@ -74,17 +73,36 @@ func (s *rcs) Platform() string {
 }
 
 // todo
-var errNoUserServiceRCS = errors.New("User services are not supported on rcS.")
+var errNoUserServiceRCS = notSupported("user service", "rcS")
 
 func (s *rcs) configPath() (cp string, err error) {
 	if s.Option.bool(optionUserService, optionUserServiceDefault) {
 		err = errNoUserServiceRCS
 		return
 	}
-	cp = "/etc/init.d/" + s.Config.Name
+	dir, err := initDir(s.Option)
+	if err != nil {
+		return
+	}
+	cp = filepath.Join(dir, s.Config.Name)
 	return
 }
 
+// startLink returns the rcS symlink starting the service installed at
+// confPath, in the rc.d directory next to its InitDir.
+func (s *rcs) startLink(confPath string) string {
+	return filepath.Join(filepath.Dir(filepath.Dir(confPath)), "rc.d", "S50"+s.Name)
+}
+
+// script runs the installed init script with action.
+func (s *rcs) script(action string) (int, string, error) {
+	cp, err := s.configPath()
+	if err != nil {
+		return 0, "", err
+	}
+	return runWithOutput(cp, action)
+}
+
 func (s *rcs) template() *template.Template {
 	customScript := s.Option.string(optionRCSScript, "")
 
@@ -95,6 +113,10 @@ func (s *rcs) template() *template.Template {
 }
 
 func (s *rcs) Install() error {
+	if err := validateName(s.Name); err != nil {
+		return err
+	}
+
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
@@ -130,11 +152,11 @@ func (s *rcs) Install() error {
 		return err
 	}
 
-	if err = os.Chmod(confPath, 0755); err != nil {
+	if err = os.Chmod(confPath, fileMode(s.Option, 0755)); err != nil {
 		return err
 	}
 
-	if err = os.Symlink(confPath, "/etc/rc.d/S50"+s.Name); err != nil {
+	if err = os.Symlink(confPath, s.startLink(confPath)); err != nil {
 		return err
 	}
 
@@ -149,7 +171,7 @@ func (s *rcs) Uninstall() error {
 	if err := os.Remove(cp); err != nil {
 		return err
 	}
-	if err := os.Remove("/etc/rc.d/S50" + s.Name); err != nil {
+	if err := os.Remove(s.startLink(cp)); err != nil {
 		return err
 	}
 	return nil
@@ -162,27 +184,41 @@ func (s *rcs) Logger(errs chan<- error) (Logger, error) {
 	return s.SystemLogger(errs)
 }
 func (s *rcs) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Option, s.Name, errs)
 }
 
 func (s *rcs) Run() (err error) {
-	err = s.i.Start(s)
+	if name := s.Option.string(optionProcessName, ""); name != "" {
+		if err = setProcessName(name); err != nil {
+			return err
+		}
+	}
+
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	err = callInterface(s, s.Option, s.i.Start)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		waitForStopSignal(s, s.i)
 	})()
 
-	return s.i.Stop(s)
+	return callInterface(s, s.Option, s.i.Stop)
 }
 
 func (s *rcs) Status() (Status, error) {
-	_, out, err := runWithOutput("/etc/init.d/"+s.Name, "status")
-	if err != nil {
+	status, err := s.status()
+	return checkHealth(context.Background(), s.Option, status, err)
+}
+
+func (s *rcs) status() (Status, error) {
+	exitCode, out, err := s.script("status")
+	// The init script exits 1 when the service is stopped.
+	if exitCode == 0 && err != nil {
 		return StatusUnknown, err
 	}
 
@@ -197,19 +233,37 @@ func (s *rcs) Status() (Status, error) {
 }
 
 func (s *rcs) Start() error {
-	return run("/etc/init.d/"+s.Name, "start")
+	_, _, err := s.script("start")
+	return err
 }
 
 func (s *rcs) Stop() error {
-	return run("/etc/init.d/"+s.Name, "stop")
+	_, _, err := s.script("stop")
+	return err
+}
+
+// DisableAndStop removes the rcS start link and stops the service.
+func (s *rcs) DisableAndStop() error {
+	cp, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.startLink(cp)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return s.Stop()
 }
 
 func (s *rcs) Restart() error {
-	err := s.Stop()
+	delay, err := restartDelay(s.Option)
 	if err != nil {
 		return err
 	}
-	time.Sleep(50 * time.Millisecond)
+	err = s.Stop()
+	if err != nil {
+		return err
+	}
+	time.Sleep(delay)
 	return s.Start()
 }
 
@@ -229,7 +283,9 @@ const rcsScript = `#!/bin/sh
 # Description:       {{.Description}}
 ### END INIT INFO
 
-cmd="{{.Path}}{{range .Arguments}} {{.|cmd}}{{end}}"
+start_cmd() {
+    exec {{.Path|cmd}}{{range .Arguments}} {{.|cmd}}{{end}}
+}
 
 name={{.Name}}
 pid_file="/var/run/$name.pid"
@@ -252,8 +308,8 @@ case "$1" in
             echo "Already started"
         else
             echo "Starting $name"
-            {{if .WorkingDirectory}}cd '{{.WorkingDirectory}}'{{end}}
-            $cmd >> "$stdout_log" 2>> "$stderr_log" &
+            {{if .WorkingDirectory}}cd {{.WorkingDirectory|cmd}}{{end}}
+            start_cmd >> "$stdout_log" 2>> "$stderr_log" &
             echo $! > "$pid_file"
             if ! is_running; then
                 echo "Unable to start, see $stdout_log and $stderr_log"
@@ -0,0 +1,112 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// rcS has no service command, the init script is run directly.
+func TestRCSStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  fakeResult
+		want    Status
+		wantErr error
+	}{
+		{"running", fakeResult{0, "Running\n"}, StatusRunning, nil},
+		{"stopped", fakeResult{1, "Stopped\n"}, StatusStopped, nil},
+		{"unknown", fakeResult{0, "\n"}, StatusUnknown, ErrNotInstalled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, restore := fakeCommandRunner(map[string]fakeResult{"/etc/init.d/test status": tt.result})
+			defer restore()
+
+			s := &rcs{Config: &Config{Name: "test"}}
+			got, err := s.Status()
+			if got != tt.want || err != tt.wantErr {
+				t.Errorf("Status() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	calls, restore := fakeCommandRunner(map[string]fakeResult{"/etc/init.d/test start": {}})
+	defer restore()
+	s := &rcs{Config: &Config{Name: "test"}}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*calls) != 1 || (*calls)[0] != "/etc/init.d/test start" {
+		t.Errorf("Start() ran %q, want the init script", *calls)
+	}
+}
+
+func TestRCSInitDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"init.d", "rc.d"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	script := filepath.Join(root, "init.d", "test")
+	link := filepath.Join(root, "rc.d", "S50test")
+	s := &rcs{Config: &Config{Name: "test", Option: KeyValue{optionInitDir: filepath.Join(root, "init.d")}}}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(link); err != nil || target != script {
+		t.Errorf("%s links to %q, %v, want %s", link, target, err, script)
+	}
+
+	calls, restore := fakeCommandRunner(map[string]fakeResult{script + " stop": {}})
+	defer restore()
+	if err := DisableAndStop(s); err != nil {
+		t.Fatal(err)
+	}
+	if len(*calls) != 1 || (*calls)[0] != script+" stop" {
+		t.Errorf("DisableAndStop() ran %q, want %s stop", *calls, script)
+	}
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("DisableAndStop() left %s, err = %v", link, err)
+	}
+}
+
+// rcS does not implement CombinedStatuser, CombinedStatus falls back to Status.
+func TestRCSCombinedStatus(t *testing.T) {
+	_, restore := fakeCommandRunner(map[string]fakeResult{"/etc/init.d/test status": {0, "Running\n"}})
+	defer restore()
+
+	got := CombinedStatus(&rcs{Config: &Config{Name: "test"}})
+	if want := (ServiceStatus{State: StatusRunning}); got != want {
+		t.Errorf("CombinedStatus() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRCSCapabilities(t *testing.T) {
+	if got := Capabilities(&rcs{Config: &Config{Name: "test"}}); got != 0 {
+		t.Errorf("Capabilities() = %b, want none", got)
+	}
+}
+
+func TestRCSLastRunResult(t *testing.T) {
+	if r, err := LastRunResult(&rcs{Config: &Config{Name: "test"}}); r != nil || err != ErrNotSupported {
+		t.Errorf("LastRunResult() = %v, %v, want %v", r, err, ErrNotSupported)
+	}
+}
+
+func TestRCSRunNow(t *testing.T) {
+	if err := RunNow(&rcs{Config: &Config{Name: "test"}}); err != ErrNotSupported {
+		t.Errorf("RunNow() error = %v, want %v", err, ErrNotSupported)
+	}
+}
@@ -0,0 +1,309 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// runitDetectEnv overrides runit detection when set to "1" (always
+// detected) or "0" (never detected).
+const runitDetectEnv = "SERVICE_DETECT_RUNIT"
+
+func isRunit() bool {
+	switch os.Getenv(runitDetectEnv) {
+	case "1":
+		return true
+	case "0":
+		return false
+	}
+	if _, err := exec.LookPath("sv"); err != nil {
+		return false
+	}
+	// runit boots Void Linux, a container usually runs runsvdir directly.
+	name, err := binaryName(1)
+	if err != nil {
+		return false
+	}
+	return name == "runit" || name == "runsvdir"
+}
+
+type runit struct {
+	i        Interface
+	platform string
+	*Config
+}
+
+func newRunitService(i Interface, platform string, c *Config) (Service, error) {
+	s := &runit{
+		i:        i,
+		platform: platform,
+		Config:   c,
+	}
+	return s, nil
+}
+
+func (s *runit) String() string {
+	if len(s.DisplayName) > 0 {
+		return s.DisplayName
+	}
+	return s.Name
+}
+
+func (s *runit) Platform() string {
+	return s.platform
+}
+
+var errNoUserServiceRunit = notSupported("user service", "runit")
+
+// configPath returns the service directory holding the run script.
+func (s *runit) configPath() (string, error) {
+	if s.Option.bool(optionUserService, optionUserServiceDefault) {
+		return "", errNoUserServiceRunit
+	}
+	dir := s.Option.string(optionRunitDir, optionRunitDirDefault)
+	if !filepath.IsAbs(dir) {
+		return "", fmt.Errorf("%s must be an absolute path, got %q", optionRunitDir, dir)
+	}
+	return filepath.Join(dir, s.Name), nil
+}
+
+// link returns the symlink in RunitServiceDir that enables the service, and
+// the path sv controls it by.
+func (s *runit) link() (string, error) {
+	dir := s.Option.string(optionRunitServiceDir, optionRunitServiceDirDefault)
+	if !filepath.IsAbs(dir) {
+		return "", fmt.Errorf("%s must be an absolute path, got %q", optionRunitServiceDir, dir)
+	}
+	return filepath.Join(dir, s.Name), nil
+}
+
+func (s *runit) template() *template.Template {
+	customScript := s.Option.string(optionRunitScript, "")
+
+	if customScript != "" {
+		return template.Must(template.New("").Funcs(tf).Parse(customScript))
+	}
+	return template.Must(template.New("").Funcs(tf).Parse(runitScript))
+}
+
+func (s *runit) Install() error {
+	if err := validateName(s.Name); err != nil {
+		return err
+	}
+	confPath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	link, err := s.link()
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(confPath); err == nil {
+		return fmt.Errorf("Init already exists: %s", confPath)
+	}
+
+	path, err := s.execPath()
+	if err != nil {
+		return err
+	}
+	var to = &struct {
+		*Config
+		Path         string
+		LogDirectory string
+	}{
+		s.Config,
+		path,
+		s.Option.string(optionLogDirectory, defaultLogDirectory),
+	}
+	var b bytes.Buffer
+	if err := s.template().Execute(&b, to); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(confPath, 0755); err != nil {
+		return err
+	}
+	runPath := filepath.Join(confPath, "run")
+	if err := ioutil.WriteFile(runPath, b.Bytes(), 0755); err != nil {
+		return err
+	}
+	if err := os.Chmod(runPath, fileMode(s.Option, 0755)); err != nil {
+		return err
+	}
+
+	if !s.Option.bool(optionEnabled, optionEnabledDefault) {
+		return nil
+	}
+	// runsvdir starts the service within 5 seconds of the link appearing.
+	return os.Symlink(confPath, link)
+}
+
+func (s *runit) Uninstall() error {
+	confPath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	link, err := s.link()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(confPath); os.IsNotExist(err) {
+		return ErrNotInstalled
+	} else if err != nil {
+		return err
+	}
+	// Removing the link makes runsvdir stop the service and its runsv.
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.RemoveAll(confPath)
+}
+
+func (s *runit) Logger(errs chan<- error) (Logger, error) {
+	if system.Interactive() {
+		return ConsoleLogger, nil
+	}
+	return s.SystemLogger(errs)
+}
+
+func (s *runit) SystemLogger(errs chan<- error) (Logger, error) {
+	return newSysLogger(s.Option, s.Name, errs)
+}
+
+func (s *runit) Run() (err error) {
+	if name := s.Option.string(optionProcessName, ""); name != "" {
+		if err = setProcessName(name); err != nil {
+			return err
+		}
+	}
+
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	err = callInterface(s, s.Option, s.i.Start)
+	if err != nil {
+		return err
+	}
+
+	s.Option.funcSingle(optionRunWait, func() {
+		waitForStopSignal(s, s.i)
+	})()
+
+	return callInterface(s, s.Option, s.i.Stop)
+}
+
+func (s *runit) Status() (Status, error) {
+	status, err := s.status()
+	return checkHealth(context.Background(), s.Option, status, err)
+}
+
+func (s *runit) status() (Status, error) {
+	confPath, err := s.configPath()
+	if err != nil {
+		return StatusUnknown, err
+	}
+	if _, err := os.Stat(confPath); os.IsNotExist(err) {
+		return StatusUnknown, ErrNotInstalled
+	}
+	link, err := s.link()
+	if err != nil {
+		return StatusUnknown, err
+	}
+	if _, err := os.Lstat(link); os.IsNotExist(err) {
+		// Not enabled, no runsv supervises it.
+		return StatusStopped, nil
+	}
+	// sv status prints "run: <dir>: (pid 123) 5s" or "down: <dir>: 3s".
+	_, out, err := runWithOutput("sv", "status", link)
+	switch {
+	case strings.HasPrefix(out, "run:"):
+		return StatusRunning, nil
+	case strings.HasPrefix(out, "down:"), strings.HasPrefix(out, "finish:"):
+		return StatusStopped, nil
+	case err != nil:
+		return StatusUnknown, err
+	default:
+		return StatusUnknown, fmt.Errorf("unexpected sv status output %q", strings.TrimSpace(out))
+	}
+}
+
+func (s *runit) Start() error {
+	return s.sv("up")
+}
+
+func (s *runit) Stop() error {
+	return s.sv("down")
+}
+
+// sv runs sv command on the service directory linked into RunitServiceDir.
+// Without the link no runsv supervises the service, so it cannot be
+// controlled until it is enabled.
+func (s *runit) sv(command string) error {
+	confPath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(confPath); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+	link, err := s.link()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(link); os.IsNotExist(err) {
+		return fmt.Errorf("service %s is not enabled, %s is missing", s.Name, link)
+	}
+	return run("sv", command, link)
+}
+
+// DisableAndStop stops the service and removes its link from RunitServiceDir.
+func (s *runit) DisableAndStop() error {
+	link, err := s.link()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(link); os.IsNotExist(err) {
+		return nil
+	}
+	if err := s.Stop(); err != nil {
+		return err
+	}
+	return os.Remove(link)
+}
+
+func (s *runit) Restart() error {
+	delay, err := restartDelay(s.Option)
+	if err != nil {
+		return err
+	}
+	err = s.Stop()
+	if err != nil {
+		return err
+	}
+	time.Sleep(delay)
+	return s.Start()
+}
+
+const runitScript = `#!/bin/sh
+# {{.Description}}
+{{range $k, $v := .EnvVars -}}
+export {{$k}}={{$v}}
+{{end -}}
+{{- if .WorkingDirectory}}
+cd {{.WorkingDirectory|cmd}} || exit 1
+{{- end}}
+exec {{if .UserName}}chpst -u {{.UserName|cmd}} {{end}}{{.Path|cmd}}{{range .Arguments}} {{.|cmd}}{{end}} >> "{{.LogDirectory}}/{{.Name}}.log" 2>> "{{.LogDirectory}}/{{.Name}}.err"
+`
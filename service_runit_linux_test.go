@@ -0,0 +1,173 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_isRunitEnvOverride(t *testing.T) {
+	defer os.Setenv(runitDetectEnv, os.Getenv(runitDetectEnv))
+
+	for value, want := range map[string]bool{"1": true, "0": false} {
+		os.Setenv(runitDetectEnv, value)
+		if got := isRunit(); got != want {
+			t.Errorf("isRunit() with %s=%s = %v, want %v", runitDetectEnv, value, got, want)
+		}
+	}
+}
+
+// newTestRunit returns a runit service installed in a temporary RunitDir
+// and RunitServiceDir under root.
+func newTestRunit(t *testing.T, root string) *runit {
+	for _, dir := range []string{"sv", "service"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &runit{Config: &Config{
+		Name:             "test",
+		Executable:       "/usr/bin/app",
+		Arguments:        []string{"-c", "/etc/app conf"},
+		WorkingDirectory: "/var/lib/app",
+		UserName:         "app",
+		Option: KeyValue{
+			optionRunitDir:        filepath.Join(root, "sv"),
+			optionRunitServiceDir: filepath.Join(root, "service"),
+		},
+	}}
+}
+
+func TestRunitInstall(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	s := newTestRunit(t, root)
+	dir := filepath.Join(root, "sv", "test")
+	link := filepath.Join(root, "service", "test")
+
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "run"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := string(b)
+	for _, want := range []string{
+		"#!/bin/sh\n",
+		"cd '/var/lib/app' || exit 1\n",
+		`exec chpst -u 'app' '/usr/bin/app' '-c' '/etc/app conf' >> "/var/log/test.log" 2>> "/var/log/test.err"`,
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("run script does not contain %q:\n%s", want, script)
+		}
+	}
+	if out, err := exec.Command("sh", "-n", "-c", script).CombinedOutput(); err != nil {
+		t.Errorf("run script is not valid shell: %v\n%s", err, out)
+	}
+	if target, err := os.Readlink(link); err != nil || target != dir {
+		t.Errorf("%s links to %q, %v, want %s", link, target, err, dir)
+	}
+	if err := s.Install(); err == nil {
+		t.Error("second Install() succeeded, want an error")
+	}
+
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{dir, link} {
+		if _, err := os.Lstat(p); !os.IsNotExist(err) {
+			t.Errorf("Uninstall() left %s, err = %v", p, err)
+		}
+	}
+	if _, err := s.Status(); err != ErrNotInstalled {
+		t.Errorf("Status() after Uninstall() error = %v, want ErrNotInstalled", err)
+	}
+	if err := s.Start(); err != ErrNotInstalled {
+		t.Errorf("Start() after Uninstall() error = %v, want ErrNotInstalled", err)
+	}
+	if err := s.Uninstall(); err != ErrNotInstalled {
+		t.Errorf("second Uninstall() error = %v, want ErrNotInstalled", err)
+	}
+}
+
+func TestRunitControl(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	s := newTestRunit(t, root)
+	s.Option[optionEnabled] = false
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Status(); got != StatusStopped || err != nil {
+		t.Errorf("Status() of a service that is not enabled = %v, %v, want %v", got, err, StatusStopped)
+	}
+	calls, restore := fakeCommandRunner(nil)
+	if err := s.Start(); err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Errorf("Start() of a service that is not enabled error = %v, want not enabled", err)
+	}
+	if err := s.Stop(); err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Errorf("Stop() of a service that is not enabled error = %v, want not enabled", err)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("Start() and Stop() of a service that is not enabled ran %q", *calls)
+	}
+	restore()
+
+	link := filepath.Join(root, "service", "test")
+	if err := os.Symlink(filepath.Join(root, "sv", "test"), link); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		result  fakeResult
+		want    Status
+		wantErr bool
+	}{
+		{"running", fakeResult{0, "run: " + link + ": (pid 123) 5s\n"}, StatusRunning, false},
+		{"down", fakeResult{0, "down: " + link + ": 3s, normally up\n"}, StatusStopped, false},
+		{"finishing", fakeResult{0, "finish: " + link + ": (pid 123) 1s\n"}, StatusStopped, false},
+		{"no runsv", fakeResult{1, "warning: " + link + ": runsv not running\n"}, StatusUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, restore := fakeCommandRunner(map[string]fakeResult{"sv status " + link: tt.result})
+			defer restore()
+			got, err := s.Status()
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("Status() = %v, %v, want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	calls, restore = fakeCommandRunner(map[string]fakeResult{
+		"sv up " + link:   {},
+		"sv down " + link: {},
+	})
+	defer restore()
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DisableAndStop(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(*calls, ","), "sv up "+link+",sv down "+link; got != want {
+		t.Errorf("Start() and DisableAndStop() ran %q, want %q", got, want)
+	}
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("DisableAndStop() left %s, err = %v", link, err)
+	}
+}
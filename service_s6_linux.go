@@ -0,0 +1,383 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// s6DetectEnv overrides s6 detection when set to "1" (always detected) or
+// "0" (never detected).
+const s6DetectEnv = "SERVICE_DETECT_S6"
+
+func isS6() bool {
+	switch os.Getenv(s6DetectEnv) {
+	case "1":
+		return true
+	case "0":
+		return false
+	}
+	if _, err := exec.LookPath("s6-svc"); err != nil {
+		return false
+	}
+	name, err := binaryName(1)
+	if err != nil {
+		return false
+	}
+	return name == "s6-svscan"
+}
+
+// hasS6RC reports whether s6-rc manages the services of this system.
+func hasS6RC() bool {
+	_, err := exec.LookPath("s6-rc")
+	return err == nil
+}
+
+type s6 struct {
+	i        Interface
+	platform string
+	*Config
+}
+
+func newS6Service(i Interface, platform string, c *Config) (Service, error) {
+	s := &s6{
+		i:        i,
+		platform: platform,
+		Config:   c,
+	}
+	return s, nil
+}
+
+func (s *s6) String() string {
+	if len(s.DisplayName) > 0 {
+		return s.DisplayName
+	}
+	return s.Name
+}
+
+func (s *s6) Platform() string {
+	return s.platform
+}
+
+var errNoUserServiceS6 = notSupported("user service", "s6")
+
+// rc reports whether the service is defined in the s6-rc source directory
+// instead of being linked into the scan directory.
+func (s *s6) rc() bool {
+	return s.Option.bool(optionS6RC, hasS6RC())
+}
+
+// absDir returns the directory of option name, which must be absolute.
+func (s *s6) absDir(name, defaultValue string) (string, error) {
+	dir := s.Option.string(name, defaultValue)
+	if !filepath.IsAbs(dir) {
+		return "", fmt.Errorf("%s must be an absolute path, got %q", name, dir)
+	}
+	return dir, nil
+}
+
+// configPath returns the service directory Install writes.
+func (s *s6) configPath() (string, error) {
+	if s.Option.bool(optionUserService, optionUserServiceDefault) {
+		return "", errNoUserServiceS6
+	}
+	name, defaultValue := optionS6Dir, optionS6DirDefault
+	if s.rc() {
+		name, defaultValue = optionS6RCSourceDir, optionS6RCSourceDirDefault
+	}
+	dir, err := s.absDir(name, defaultValue)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, s.Name), nil
+}
+
+// liveDir returns the path s6-supervise runs the service from, the link in
+// the scan directory.
+func (s *s6) liveDir() (string, error) {
+	dir, err := s.absDir(optionS6ScanDir, optionS6ScanDirDefault)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, s.Name), nil
+}
+
+// bundleEntry returns the file that adds the service to the default bundle
+// of s6-rc.
+func (s *s6) bundleEntry() (string, error) {
+	dir, err := s.absDir(optionS6RCSourceDir, optionS6RCSourceDirDefault)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "default", "contents.d", s.Name), nil
+}
+
+func (s *s6) template() *template.Template {
+	customScript := s.Option.string(optionS6Script, "")
+
+	if customScript != "" {
+		return template.Must(template.New("").Funcs(tf).Parse(customScript))
+	}
+	return template.Must(template.New("").Funcs(tf).Parse(s6Script))
+}
+
+func (s *s6) Install() error {
+	if err := validateName(s.Name); err != nil {
+		return err
+	}
+	confPath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(confPath); err == nil {
+		return fmt.Errorf("Init already exists: %s", confPath)
+	}
+
+	path, err := s.execPath()
+	if err != nil {
+		return err
+	}
+	logDir := s.Option.string(optionLogDirectory, defaultLogDirectory)
+	var to = &struct {
+		*Config
+		Path         string
+		LogDirectory string
+	}{
+		s.Config,
+		path,
+		logDir,
+	}
+	var runScript bytes.Buffer
+	if err := s.template().Execute(&runScript, to); err != nil {
+		return err
+	}
+	// finish runs with the exit code of run, or 256 and the signal if it
+	// was killed.
+	quote := tf["cmd"].(func(string) string)
+	finish := "#!/bin/sh\necho \"exited with $1 $2\" >> " + quote(logDir+"/"+s.Name+".err") + "\n"
+
+	enabled := s.Option.bool(optionEnabled, optionEnabledDefault)
+	files := map[string]string{"run": runScript.String(), "finish": finish}
+	if s.rc() {
+		files["type"] = "longrun\n"
+	} else if !enabled {
+		files["down"] = ""
+	}
+	if err := os.MkdirAll(confPath, 0755); err != nil {
+		return err
+	}
+	for name, content := range files {
+		mode := os.FileMode(0644)
+		if name == "run" || name == "finish" {
+			mode = fileMode(s.Option, 0755)
+		}
+		p := filepath.Join(confPath, name)
+		if err := ioutil.WriteFile(p, []byte(content), mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(p, mode); err != nil {
+			return err
+		}
+	}
+
+	if s.rc() {
+		if !enabled {
+			return nil
+		}
+		entry, err := s.bundleEntry()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(entry), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(entry, nil, 0644)
+	}
+	live, err := s.liveDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Symlink(confPath, live); err != nil {
+		return err
+	}
+	return run("s6-svscanctl", "-a", filepath.Dir(live))
+}
+
+func (s *s6) Uninstall() error {
+	confPath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(confPath); err != nil {
+		return err
+	}
+	if s.rc() {
+		entry, err := s.bundleEntry()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(entry); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return os.RemoveAll(confPath)
+	}
+	live, err := s.liveDir()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(live); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// -n stops the supervisors of services no longer in the scan directory.
+	rescanErr := run("s6-svscanctl", "-an", filepath.Dir(live))
+	if err := os.RemoveAll(confPath); err != nil {
+		return err
+	}
+	return rescanErr
+}
+
+func (s *s6) Logger(errs chan<- error) (Logger, error) {
+	if system.Interactive() {
+		return ConsoleLogger, nil
+	}
+	return s.SystemLogger(errs)
+}
+
+func (s *s6) SystemLogger(errs chan<- error) (Logger, error) {
+	return newSysLogger(s.Option, s.Name, errs)
+}
+
+func (s *s6) Run() (err error) {
+	if name := s.Option.string(optionProcessName, ""); name != "" {
+		if err = setProcessName(name); err != nil {
+			return err
+		}
+	}
+
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	err = callInterface(s, s.Option, s.i.Start)
+	if err != nil {
+		return err
+	}
+
+	s.Option.funcSingle(optionRunWait, func() {
+		waitForStopSignal(s, s.i)
+	})()
+
+	return callInterface(s, s.Option, s.i.Stop)
+}
+
+func (s *s6) Status() (Status, error) {
+	status, err := s.status()
+	return checkHealth(context.Background(), s.Option, status, err)
+}
+
+func (s *s6) status() (Status, error) {
+	confPath, err := s.configPath()
+	if err != nil {
+		return StatusUnknown, err
+	}
+	if _, err := os.Stat(confPath); os.IsNotExist(err) {
+		return StatusUnknown, ErrNotInstalled
+	}
+	live, err := s.liveDir()
+	if err != nil {
+		return StatusUnknown, err
+	}
+	if _, err := os.Lstat(live); os.IsNotExist(err) {
+		// Not supervised, for s6-rc the database has not been updated.
+		return StatusStopped, nil
+	}
+	// s6-svstat prints "up (pid 123) 5 seconds" or "down (exitcode 0) 3 seconds".
+	_, out, err := runWithOutput("s6-svstat", live)
+	switch {
+	case strings.HasPrefix(out, "up "):
+		return StatusRunning, nil
+	case strings.HasPrefix(out, "down "):
+		return StatusStopped, nil
+	case err != nil:
+		return StatusUnknown, err
+	default:
+		return StatusUnknown, fmt.Errorf("unexpected s6-svstat output %q", strings.TrimSpace(out))
+	}
+}
+
+func (s *s6) Start() error {
+	return s.control("-u")
+}
+
+func (s *s6) Stop() error {
+	return s.control("-d")
+}
+
+// control brings the service up with -u or down with -d, through s6-rc if
+// it manages the service.
+func (s *s6) control(flag string) error {
+	if s.rc() {
+		return run("s6-rc", flag, "change", s.Name)
+	}
+	live, err := s.liveDir()
+	if err != nil {
+		return err
+	}
+	return run("s6-svc", flag, live)
+}
+
+// DisableAndStop keeps the service from starting at boot and stops it. The
+// down file does so for s6-svscan, s6-rc drops it from the default bundle.
+func (s *s6) DisableAndStop() error {
+	confPath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	if s.rc() {
+		entry, err := s.bundleEntry()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(entry); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := ioutil.WriteFile(filepath.Join(confPath, "down"), nil, 0644); err != nil {
+		return err
+	}
+	return s.Stop()
+}
+
+func (s *s6) Restart() error {
+	delay, err := restartDelay(s.Option)
+	if err != nil {
+		return err
+	}
+	err = s.Stop()
+	if err != nil {
+		return err
+	}
+	time.Sleep(delay)
+	return s.Start()
+}
+
+const s6Script = `#!/bin/sh
+# {{.Description}}
+{{range $k, $v := .EnvVars -}}
+export {{$k}}={{$v}}
+{{end -}}
+{{- if .WorkingDirectory}}
+cd {{.WorkingDirectory|cmd}} || exit 1
+{{- end}}
+exec {{if .UserName}}s6-setuidgid {{.UserName|cmd}} {{end}}{{.Path|cmd}}{{range .Arguments}} {{.|cmd}}{{end}} >> "{{.LogDirectory}}/{{.Name}}.log" 2>> "{{.LogDirectory}}/{{.Name}}.err"
+`
@@ -0,0 +1,177 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestS6 returns an s6 service installed in temporary directories under
+// root, defined for s6-rc if rc is set.
+func newTestS6(t *testing.T, root string, rc bool) *s6 {
+	for _, dir := range []string{"sv", "service", "source"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &s6{Config: &Config{
+		Name:       "test",
+		Executable: "/usr/bin/app",
+		Arguments:  []string{"-c", "/etc/app conf"},
+		UserName:   "app",
+		Option: KeyValue{
+			optionS6RC:          rc,
+			optionS6Dir:         filepath.Join(root, "sv"),
+			optionS6ScanDir:     filepath.Join(root, "service"),
+			optionS6RCSourceDir: filepath.Join(root, "source"),
+		},
+	}}
+}
+
+func TestS6Install(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	s := newTestS6(t, root, false)
+	dir := filepath.Join(root, "sv", "test")
+	live := filepath.Join(root, "service", "test")
+
+	calls, restore := fakeCommandRunner(map[string]fakeResult{
+		"s6-svscanctl -a " + filepath.Join(root, "service"):  {},
+		"s6-svscanctl -an " + filepath.Join(root, "service"): {},
+	})
+	defer restore()
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "run"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := string(b)
+	if want := `exec s6-setuidgid 'app' '/usr/bin/app' '-c' '/etc/app conf' >> "/var/log/test.log" 2>> "/var/log/test.err"`; !strings.Contains(run, want) {
+		t.Errorf("run script does not contain %q:\n%s", want, run)
+	}
+	for _, name := range []string{"run", "finish"} {
+		if out, err := exec.Command("sh", "-n", filepath.Join(dir, name)).CombinedOutput(); err != nil {
+			t.Errorf("%s script is not valid shell: %v\n%s", name, err, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "down")); !os.IsNotExist(err) {
+		t.Errorf("Install() of an enabled service wrote a down file, err = %v", err)
+	}
+	if target, err := os.Readlink(live); err != nil || target != dir {
+		t.Errorf("%s links to %q, %v, want %s", live, target, err, dir)
+	}
+
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{dir, live} {
+		if _, err := os.Lstat(p); !os.IsNotExist(err) {
+			t.Errorf("Uninstall() left %s, err = %v", p, err)
+		}
+	}
+	if got, want := strings.Join(*calls, ","), "s6-svscanctl -a "+filepath.Join(root, "service")+",s6-svscanctl -an "+filepath.Join(root, "service"); got != want {
+		t.Errorf("Install() and Uninstall() ran %q, want %q", got, want)
+	}
+}
+
+func TestS6RCInstall(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	s := newTestS6(t, root, true)
+	dir := filepath.Join(root, "source", "test")
+	entry := filepath.Join(root, "source", "default", "contents.d", "test")
+
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "type")); err != nil || string(b) != "longrun\n" {
+		t.Errorf("type = %q, %v, want longrun", b, err)
+	}
+	if _, err := os.Stat(entry); err != nil {
+		t.Errorf("Install() did not add the service to the default bundle: %v", err)
+	}
+
+	calls, restore := fakeCommandRunner(map[string]fakeResult{
+		"s6-rc -u change test": {},
+		"s6-rc -d change test": {},
+	})
+	defer restore()
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DisableAndStop(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(*calls, ","), "s6-rc -u change test,s6-rc -d change test"; got != want {
+		t.Errorf("Start() and DisableAndStop() ran %q, want %q", got, want)
+	}
+	if _, err := os.Stat(entry); !os.IsNotExist(err) {
+		t.Errorf("DisableAndStop() left %s, err = %v", entry, err)
+	}
+
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Uninstall() left %s, err = %v", dir, err)
+	}
+}
+
+func TestS6Status(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	s := newTestS6(t, root, false)
+	if _, err := s.Status(); err != ErrNotInstalled {
+		t.Errorf("Status() before Install() error = %v, want ErrNotInstalled", err)
+	}
+
+	s.Option[optionEnabled] = false
+	live := filepath.Join(root, "service", "test")
+	_, restore := fakeCommandRunner(map[string]fakeResult{"s6-svscanctl -a " + filepath.Join(root, "service"): {}})
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	restore()
+	if _, err := os.Stat(filepath.Join(root, "sv", "test", "down")); err != nil {
+		t.Errorf("Install() of a disabled service wrote no down file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		result  fakeResult
+		want    Status
+		wantErr bool
+	}{
+		{"up", fakeResult{0, "up (pid 123) 5 seconds\n"}, StatusRunning, false},
+		{"down", fakeResult{0, "down (exitcode 0) 3 seconds, normally up, ready 3 seconds\n"}, StatusStopped, false},
+		{"not supervised", fakeResult{111, ""}, StatusUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, restore := fakeCommandRunner(map[string]fakeResult{"s6-svstat " + live: tt.result})
+			defer restore()
+			got, err := s.Status()
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("Status() = %v, %v, want %v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
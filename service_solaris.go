@@ -6,14 +6,12 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"os"
-	"os/signal"
-	"regexp"
-	"syscall"
+	"strings"
 	"text/template"
-	"time"
 )
 
 const maxPathSize = 32 * 1024
@@ -102,12 +100,19 @@ func (s *solarisService) configPath() (string, error) {
 	return "/lib/svc/manifest/" + s.Prefix + "/" + s.Config.Name + ".xml", nil
 }
 
+// getService returns the FMRI of the service, without an instance.
+func (s *solarisService) getService() string {
+	return "svc:/" + s.Prefix + "/" + s.Config.Name
+}
+
 func (s *solarisService) getFMRI() string {
-	return "svc:/" + s.Prefix + "/" + s.Config.Name + ":default"
+	return s.getService() + ":default"
 }
 
 func (s *solarisService) Install() error {
-	// write start script
+	if err := validateName(s.Name); err != nil {
+		return err
+	}
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
@@ -117,12 +122,6 @@ func (s *solarisService) Install() error {
 		return fmt.Errorf("Manifest already exists: %s", confPath)
 	}
 
-	f, err := os.Create(confPath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
 	path, err := s.execPath()
 	if err != nil {
 		return err
@@ -144,18 +143,25 @@ func (s *solarisService) Install() error {
 		path,
 	}
 
-	err = s.template().Execute(f, to)
+	f, err := os.Create(confPath)
 	if err != nil {
 		return err
 	}
-
-	// import service
-	err = run("svcadm", "restart", "manifest-import")
+	err = f.Chmod(fileMode(s.Option, 0644))
+	if err == nil {
+		err = s.template().Execute(f, to)
+	}
+	// Closed before svccfg reads it.
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
 	if err != nil {
 		return err
 	}
 
-	return nil
+	// svccfg imports the manifest before it returns, unlike a restart of
+	// manifest-import.
+	return run("/usr/sbin/svccfg", "import", confPath)
 }
 
 func (s *solarisService) Uninstall() error {
@@ -165,38 +171,38 @@ func (s *solarisService) Uninstall() error {
 	if err != nil {
 		return err
 	}
-	err = os.Remove(confPath)
-	if err != nil {
-		return err
-	}
-
-	// unregister service
-	err = run("svcadm", "restart", "manifest-import")
-	if err != nil {
+	// Deletes the service along with its default instance.
+	if err = run("/usr/sbin/svccfg", "delete", "-f", s.getService()); err != nil {
 		return err
 	}
-
-	return nil
+	return os.Remove(confPath)
 }
 
 func (s *solarisService) Status() (Status, error) {
-	fmri := s.getFMRI()
-	exitCode, out, err := runWithOutput("svcs", fmri)
+	status, err := s.status()
+	return checkHealth(context.Background(), s.Option, status, err)
+}
+
+func (s *solarisService) status() (Status, error) {
+	// -H -o state prints only the state, such as online or disabled.
+	exitCode, out, err := runWithOutput("/usr/bin/svcs", "-H", "-o", "state", s.getFMRI())
 	if exitCode != 0 {
 		return StatusUnknown, ErrNotInstalled
 	}
+	if err != nil {
+		return StatusUnknown, err
+	}
 
-	re := regexp.MustCompile(`(degraded|disabled|legacy_run|maintenance|offline|online)\s+\w+` + fmri)
-	matches := re.FindStringSubmatch(out)
-	if len(matches) == 2 {
-		status := string(matches[1])
-		if status == "online" {
-			return StatusRunning, nil
-		} else {
-			return StatusStopped, nil
-		}
+	switch state := strings.TrimSpace(out); state {
+	case "online":
+		return StatusRunning, nil
+	case "degraded":
+		return StatusDegraded, nil
+	case "disabled", "offline", "maintenance", "legacy_run", "uninitialized":
+		return StatusStopped, nil
+	default:
+		return StatusUnknown, fmt.Errorf("unexpected svcs state %q", state)
 	}
-	return StatusUnknown, err
 }
 
 func (s *solarisService) Start() error {
@@ -205,30 +211,41 @@ func (s *solarisService) Start() error {
 func (s *solarisService) Stop() error {
 	return run("/usr/sbin/svcadm", "disable", s.getFMRI())
 }
+
+// DisableAndStop is Stop, svcadm disable is persistent across reboots.
+func (s *solarisService) DisableAndStop() error {
+	return s.Stop()
+}
+
+// Restart has svcadm restart a running service and starts a stopped one.
 func (s *solarisService) Restart() error {
-	err := s.Stop()
+	status, err := s.status()
 	if err != nil {
 		return err
 	}
-	time.Sleep(50 * time.Millisecond)
-	return s.Start()
+	if status == StatusStopped {
+		return s.Start()
+	}
+	return run("/usr/sbin/svcadm", "restart", s.getFMRI())
 }
 
 func (s *solarisService) Run() error {
 	var err error
 
-	err = s.i.Start(s)
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	err = callInterface(s, s.Option, s.i.Start)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		waitForStopSignal(s, s.i)
 	})()
 
-	return s.i.Stop(s)
+	return callInterface(s, s.Option, s.i.Stop)
 }
 
 func (s *solarisService) Logger(errs chan<- error) (Logger, error) {
@@ -238,7 +255,7 @@ func (s *solarisService) Logger(errs chan<- error) (Logger, error) {
 	return s.SystemLogger(errs)
 }
 func (s *solarisService) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Option, s.Name, errs)
 }
 
 var manifest = `<?xml version="1.0"?>
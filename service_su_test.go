@@ -74,6 +74,40 @@ func TestInstallRunRestartStopRemove(t *testing.T) {
 	}
 }
 
+// TestInstallDisableAndStop covers DisableAndStop on the real service manager,
+// the Windows backend talks to the SCM without running commands.
+func TestInstallDisableAndStop(t *testing.T) {
+	p := &program{}
+	reportDir := mustTempDir(t)
+	defer os.RemoveAll(reportDir)
+
+	s := mustNewRunAsService(t, p, reportDir)
+	_ = s.Uninstall()
+
+	if err := s.Install(); err != nil {
+		t.Fatal("Install", err)
+	}
+	defer s.Uninstall()
+
+	if err := s.Start(); err != nil {
+		t.Fatal("Start", err)
+	}
+	defer s.Stop()
+	checkReport(t, reportDir, "Start()", 1, 0)
+
+	if err := service.DisableAndStop(s); err != nil {
+		t.Fatal("DisableAndStop", err)
+	}
+	checkReport(t, reportDir, "DisableAndStop()", 1, 1)
+	if status, err := s.Status(); err != nil || status != service.StatusStopped {
+		t.Errorf("Status() after DisableAndStop() = %v, %v, want stopped", status, err)
+	}
+
+	if err := s.Uninstall(); err != nil {
+		t.Fatal("uninstall", err)
+	}
+}
+
 func runService() {
 	p := &program{}
 	sc := &service.Config{
@@ -6,17 +6,20 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 	"text/template"
+	"time"
 )
 
 func isSystemd() bool {
@@ -48,6 +51,8 @@ type systemd struct {
 	i        Interface
 	platform string
 	*Config
+
+	userService bool
 }
 
 func newSystemdService(i Interface, platform string, c *Config) (Service, error) {
@@ -55,6 +60,8 @@ func newSystemdService(i Interface, platform string, c *Config) (Service, error)
 		i:        i,
 		platform: platform,
 		Config:   c,
+
+		userService: preferUserService(c.Option),
 	}
 
 	return s, nil
@@ -71,6 +78,94 @@ func (s *systemd) Platform() string {
 	return s.platform
 }
 
+func (s *systemd) Capabilities() Capability {
+	c := CapabilityUserService
+	if s.Option.string(optionReloadSignal, "") != "" {
+		c |= CapabilityReload
+	}
+	return c
+}
+
+// RunNow starts the service of a timer without waiting for the timer to elapse.
+func (s *systemd) RunNow() error {
+	cp, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	if !s.hasUnitFile(filepath.Dir(cp), s.timerName()) {
+		return ErrNotSupported
+	}
+	return s.runAction("start")
+}
+
+func (s *systemd) timerName() string {
+	return s.Config.Name + ".timer"
+}
+
+func (s *systemd) LastRunResult() (*RunResult, error) {
+	_, out, err := s.runWithOutput("systemctl", "show", s.unitName(), "-p", "ExecMainStatus,ExecMainExitTimestamp,Result")
+	if err != nil {
+		return nil, err
+	}
+	return parseSystemdRunResult(out)
+}
+
+// Properties returns every property systemctl show prints for the unit.
+func (s *systemd) Properties() (map[string]string, error) {
+	_, out, err := s.runWithOutput("systemctl", "show", s.unitName())
+	if err != nil {
+		return nil, err
+	}
+	props := parseSystemdProperties(out)
+	if props["LoadState"] == "not-found" {
+		return nil, ErrNotInstalled
+	}
+	return props, nil
+}
+
+// parseSystemdProperties parses the Key=Value lines printed by systemctl show.
+func parseSystemdProperties(out string) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) == 2 {
+			props[kv[0]] = kv[1]
+		}
+	}
+	return props
+}
+
+// systemdTimestampLayout is the format of timestamp properties in systemctl show.
+const systemdTimestampLayout = "Mon 2006-01-02 15:04:05 MST"
+
+// parseSystemdRunResult reads the ExecMainStatus, ExecMainExitTimestamp and
+// Result properties printed by systemctl show.
+func parseSystemdRunResult(out string) (*RunResult, error) {
+	props := parseSystemdProperties(out)
+	result, ok := props["Result"]
+	if !ok {
+		return nil, fmt.Errorf("systemctl show output has no Result: %q", out)
+	}
+
+	r := &RunResult{}
+	if v := props["ExecMainStatus"]; v != "" {
+		code, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ExecMainStatus %q: %v", v, err)
+		}
+		r.ExitCode = code
+	}
+	if v := props["ExecMainExitTimestamp"]; v != "" && v != "n/a" {
+		t, err := time.ParseInLocation(systemdTimestampLayout, v, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ExecMainExitTimestamp %q: %v", v, err)
+		}
+		r.FinishedAt = t
+	}
+	r.Success = result == "success" && !r.FinishedAt.IsZero()
+	return r, nil
+}
+
 func (s *systemd) configPath() (cp string, err error) {
 	if !s.isUserService() {
 		cp = "/etc/systemd/system/" + s.unitName()
@@ -93,7 +188,22 @@ func (s *systemd) unitName() string {
 	return s.Config.Name + ".service"
 }
 
+// systemdVersionOnce guards systemdVersion, systemctl --version is run once
+// per process rather than for every unit rendered.
+var (
+	systemdVersionOnce = new(sync.Once)
+	systemdVersion     int64
+)
+
+// getSystemdVersion returns the systemd version, -1 if it is unknown.
 func (s *systemd) getSystemdVersion() int64 {
+	systemdVersionOnce.Do(func() {
+		systemdVersion = s.querySystemdVersion()
+	})
+	return systemdVersion
+}
+
+func (s *systemd) querySystemdVersion() int64 {
 	_, out, err := s.runWithOutput("systemctl", "--version")
 	if err != nil {
 		return -1
@@ -113,6 +223,13 @@ func (s *systemd) getSystemdVersion() int64 {
 	return v
 }
 
+// hasConfigurationDirectorySupport reports whether ConfigurationDirectory=
+// is understood, it was added in systemd 235.
+func (s *systemd) hasConfigurationDirectorySupport() bool {
+	version := s.getSystemdVersion()
+	return version == -1 || version >= 235
+}
+
 func (s *systemd) hasOutputFileSupport() bool {
 	defaultValue := true
 	version := s.getSystemdVersion()
@@ -127,24 +244,114 @@ func (s *systemd) hasOutputFileSupport() bool {
 	return defaultValue
 }
 
+// systemdTf overrides the shell quoting in tf, systemd unit files
+// use their own quoting rules.
+var systemdTf = map[string]interface{}{
+	"cmd": func(s string) string {
+		return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+	},
+}
+
 func (s *systemd) template() *template.Template {
 	customScript := s.Option.string(optionSystemdScript, "")
 
 	if customScript != "" {
-		return template.Must(template.New("").Funcs(tf).Parse(customScript))
+		return template.Must(template.New("").Funcs(tf).Funcs(systemdTf).Parse(customScript))
 	}
-	return template.Must(template.New("").Funcs(tf).Parse(systemdScript))
+	return template.Must(template.New("").Funcs(tf).Funcs(systemdTf).Parse(systemdScript))
 }
 
 func (s *systemd) isUserService() bool {
-	return s.Option.bool(optionUserService, optionUserServiceDefault)
+	return s.userService
 }
 
 func (s *systemd) Install() error {
+	_, err := s.InstallWithResult()
+	return err
+}
+
+func (s *systemd) InstallWithResult() (InstallResult, error) {
+	result := InstallResult{UserService: s.isUserService()}
+	return result, s.install()
+}
+
+// restart returns the value of the Restart= setting.
+func (s *systemd) restart() (string, error) {
+	policy, err := restartPolicy(s.Option)
+	if err != nil {
+		return "", err
+	}
+	if policy == "" {
+		policy = "always"
+	}
+	return s.Option.string(optionRestart, policy), nil
+}
+
+// systemdUnitDirs lists the directories searched for a main unit file,
+// besides the one Install writes to.
+var systemdUnitDirs = []string{
+	"/etc/systemd/system",
+	"/run/systemd/system",
+	"/usr/lib/systemd/system",
+	"/lib/systemd/system",
+}
+
+func (s *systemd) dropInOnly() bool {
+	return s.Option.bool(optionDropInOnly, optionDropInOnlyDefault)
+}
+
+// dropInPath returns the path of the drop-in written instead of the main
+// unit when DropInOnly is set.
+func (s *systemd) dropInPath() (string, error) {
+	cp, err := s.configPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cp+".d", "override.conf"), nil
+}
+
+// hasUnitFile reports if the unit file exists in dir, the directory Install
+// writes to, or for a system service in one of the system unit directories.
+func (s *systemd) hasUnitFile(dir, unit string) bool {
+	if _, err := os.Stat(filepath.Join(dir, unit)); err == nil {
+		return true
+	}
+	if s.isUserService() {
+		return false
+	}
+	for _, d := range systemdUnitDirs {
+		if _, err := os.Stat(filepath.Join(d, unit)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *systemd) install() error {
+	_, err := s.restart()
+	if err != nil {
+		return err
+	}
+	if s.transient() {
+		return nil
+	}
+
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
 	}
+	dropIn := s.dropInOnly()
+	if dropIn {
+		if !s.hasUnitFile(filepath.Dir(confPath), s.unitName()) {
+			return fmt.Errorf("%s is set and there is no main unit for %s, refusing to write one", optionDropInOnly, s.unitName())
+		}
+		if confPath, err = s.dropInPath(); err != nil {
+			return err
+		}
+		if err = os.MkdirAll(filepath.Dir(confPath), 0755); err != nil {
+			return err
+		}
+	}
 	_, err = os.Stat(confPath)
 	if err == nil {
 		return fmt.Errorf("Init already exists: %s", confPath)
@@ -156,14 +363,99 @@ func (s *systemd) Install() error {
 	}
 	defer f.Close()
 
+	if err = f.Chmod(fileMode(s.Option, 0644)); err != nil {
+		return err
+	}
+
+	err = s.render(f, dropIn)
+	if err != nil {
+		return err
+	}
+
+	if s.restoreCon() {
+		if err = run("restorecon", confPath); err != nil {
+			return err
+		}
+	}
+
+	err = s.runAction("enable")
+	if err != nil {
+		return err
+	}
+
+	return s.run("daemon-reload")
+}
+
+// Logs returns the last lines the journal holds for the unit, or of the
+// LogOutput files when systemd writes the output there.
+func (s *systemd) Logs(lines int) ([]byte, error) {
+	if s.Option.bool(optionLogOutput, optionLogOutputDefault) && s.hasOutputFileSupport() {
+		logDir := s.Option.string(optionLogDirectory, defaultLogDirectory)
+		return tailLogs(lines, filepath.Join(logDir, s.Name+".out"), filepath.Join(logDir, s.Name+".err"))
+	}
+	if lines <= 0 {
+		return nil, fmt.Errorf("lines must be positive, got %d", lines)
+	}
+	unit := "--unit=" + s.unitName()
+	if s.isUserService() {
+		unit = "--user-unit=" + s.unitName()
+	}
+	_, out, err := runWithOutput("journalctl", unit, "--lines="+strconv.Itoa(lines), "--no-pager")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// restoreCon reports whether Install relabels the unit file with restorecon.
+func (s *systemd) restoreCon() bool {
+	_, err := exec.LookPath("restorecon")
+	return s.Option.bool(optionRestoreCon, err == nil)
+}
+
+// render writes the unit file to w. A drop-in resets ExecStart so that it
+// replaces the command of the main unit.
+func (s *systemd) render(w io.Writer, dropIn bool) error {
+	restart, err := s.restart()
+	if err != nil {
+		return err
+	}
+
 	path, err := s.execPath()
 	if err != nil {
 		return err
 	}
 
+	selinuxContext, err := s.confinementOption(optionSELinuxContext)
+	if err != nil {
+		return err
+	}
+	appArmorProfile, err := s.confinementOption(optionAppArmorProfile)
+	if err != nil {
+		return err
+	}
+
+	configDir, configDirMode, err := s.configDirectory()
+	if err != nil {
+		return err
+	}
+
+	startLimitAction, err := s.startLimitAction()
+	if err != nil {
+		return err
+	}
+	burst := s.Option.int(optionStartLimitBurst, optionStartLimitBurstDefault)
+	if burst < 0 {
+		return fmt.Errorf("%s must not be negative, got %d", optionStartLimitBurst, burst)
+	}
+
 	var to = &struct {
 		*Config
 		Path                 string
+		DropIn               bool
+		StartLimitInterval   string
+		StartLimitBurst      int
+		StartLimitAction     string
 		HasOutputFileSupport bool
 		ReloadSignal         string
 		PIDFile              string
@@ -172,44 +464,123 @@ func (s *systemd) Install() error {
 		SuccessExitStatus    string
 		LogOutput            bool
 		LogDirectory         string
+		SELinuxContext       string
+		AppArmorProfile      string
+		AfterNetworkOnline   bool
+		ConfigDirectory      string
+		ConfigDirectoryMode  string
 	}{
 		s.Config,
 		path,
+		dropIn,
+		systemdTimeSpan(s.Option.duration(optionStartLimitInterval, optionStartLimitIntervalDefault)),
+		burst,
+		startLimitAction,
 		s.hasOutputFileSupport(),
 		s.Option.string(optionReloadSignal, ""),
 		s.Option.string(optionPIDFile, ""),
 		s.Option.int(optionLimitNOFILE, optionLimitNOFILEDefault),
-		s.Option.string(optionRestart, "always"),
+		restart,
 		s.Option.string(optionSuccessExitStatus, ""),
 		s.Option.bool(optionLogOutput, optionLogOutputDefault),
 		s.Option.string(optionLogDirectory, defaultLogDirectory),
+		selinuxContext,
+		appArmorProfile,
+		s.Option.bool(optionAfterNetworkOnline, optionAfterNetworkOnlineDefault),
+		configDir,
+		configDirMode,
 	}
 
-	err = s.template().Execute(f, to)
-	if err != nil {
-		return err
+	return s.template().Execute(w, to)
+}
+
+// configDirectory returns the ConfigDirectory option and its mode in octal,
+// both empty when it is not set or systemd does not support it.
+func (s *systemd) configDirectory() (dir, mode string, err error) {
+	dir = s.Option.string(optionConfigDirectory, "")
+	if dir == "" {
+		return "", "", nil
+	}
+	if filepath.IsAbs(dir) || strings.ContainsAny(dir, " \r\n") {
+		return "", "", fmt.Errorf("%s must be a single relative path below /etc, got %q", optionConfigDirectory, dir)
+	}
+	if !s.hasConfigurationDirectorySupport() {
+		return "", "", nil
+	}
+	if _, found := s.Option[optionConfigurationDirectoryMode]; found {
+		mode = fmt.Sprintf("%04o", modeOption(s.Option, optionConfigurationDirectoryMode, 0755))
 	}
+	return dir, mode, nil
+}
 
-	err = s.runAction("enable")
-	if err != nil {
-		return err
+// startLimitActions lists the actions systemd accepts for StartLimitAction.
+var startLimitActions = []string{
+	"none",
+	"reboot", "reboot-force", "reboot-immediate",
+	"poweroff", "poweroff-force", "poweroff-immediate",
+	"exit", "exit-force",
+	"soft-reboot", "soft-reboot-force",
+	"kexec", "kexec-force",
+	"halt", "halt-force", "halt-immediate",
+}
+
+// startLimitAction returns the StartLimitAction option, empty if unset.
+func (s *systemd) startLimitAction() (string, error) {
+	action := s.Option.string(optionStartLimitAction, "")
+	if action == "" {
+		return "", nil
 	}
+	for _, a := range startLimitActions {
+		if action == a {
+			return action, nil
+		}
+	}
+	return "", fmt.Errorf("invalid %s %q, want one of %s", optionStartLimitAction, action, strings.Join(startLimitActions, ", "))
+}
 
-	return s.run("daemon-reload")
+// systemdTimeSpan formats d as a systemd time span, whole seconds without a unit.
+func systemdTimeSpan(d time.Duration) string {
+	if d%time.Second == 0 {
+		return strconv.FormatInt(int64(d/time.Second), 10)
+	}
+	return d.String()
+}
+
+// confinementOption returns the SELinuxContext or AppArmorProfile option.
+// When set it must be a non-empty single line.
+func (s *systemd) confinementOption(name string) (string, error) {
+	if _, found := s.Option[name]; !found {
+		return "", nil
+	}
+	v := s.Option.string(name, "")
+	if strings.TrimSpace(v) == "" || strings.ContainsAny(v, "\r\n") {
+		return "", fmt.Errorf("%s must be a non-empty single line, got %q", name, v)
+	}
+	return v, nil
 }
 
 func (s *systemd) Uninstall() error {
+	if s.transient() {
+		return nil
+	}
 	err := s.runAction("disable")
 	if err != nil {
 		return err
 	}
 	cp, err := s.configPath()
+	if s.dropInOnly() {
+		cp, err = s.dropInPath()
+	}
 	if err != nil {
 		return err
 	}
 	if err := os.Remove(cp); err != nil {
 		return err
 	}
+	if s.dropInOnly() {
+		// Only succeeds if nothing else was dropped in.
+		os.Remove(filepath.Dir(cp))
+	}
 	return s.run("daemon-reload")
 }
 
@@ -220,25 +591,75 @@ func (s *systemd) Logger(errs chan<- error) (Logger, error) {
 	return s.SystemLogger(errs)
 }
 func (s *systemd) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Option, s.Name, errs)
 }
 
 func (s *systemd) Run() (err error) {
-	err = s.i.Start(s)
+	if name := s.Option.string(optionProcessName, ""); name != "" {
+		if err = setProcessName(name); err != nil {
+			return err
+		}
+	}
+
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	err = callInterface(s, s.Option, s.i.Start)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		waitForStopSignal(s, s.i)
 	})()
 
-	return s.i.Stop(s)
+	return callInterface(s, s.Option, s.i.Stop)
 }
 
+// Status reports the state of the unit as systemctl is-active does, callers
+// comparing it with systemctl should not see another state. CombinedStatus
+// runs HealthCheckCommand.
 func (s *systemd) Status() (Status, error) {
+	return s.status()
+}
+
+// CombinedStatus adds the MainPID, StateChangeTimestamp and a failed Result
+// of the unit to its Status, and the result of HealthCheckCommand.
+func (s *systemd) CombinedStatus() ServiceStatus {
+	state, err := s.Status()
+	st := ServiceStatus{State: state, LastError: err}
+	health, healthErr := checkHealth(context.Background(), s.Option, state, err)
+	if health == StatusDegraded {
+		st.Degraded = true
+	} else if healthErr != nil && err == nil {
+		st.LastError = healthErr
+	}
+	_, out, showErr := s.runWithOutput("systemctl", "show", s.unitName(), "-p", "MainPID,StateChangeTimestamp,Result")
+	if showErr != nil {
+		if st.LastError == nil {
+			st.LastError = showErr
+		}
+		return st
+	}
+	parseSystemdStatus(out, &st)
+	return st
+}
+
+// parseSystemdStatus fills st from the MainPID, StateChangeTimestamp and
+// Result properties printed by systemctl show.
+func parseSystemdStatus(out string, st *ServiceStatus) {
+	props := parseSystemdProperties(out)
+	st.PID, _ = strconv.Atoi(props["MainPID"])
+	if v := props["StateChangeTimestamp"]; v != "" && v != "n/a" {
+		st.Since, _ = time.ParseInLocation(systemdTimestampLayout, v, time.Local)
+	}
+	if result := props["Result"]; result != "" && result != "success" && st.LastError == nil {
+		st.LastError = fmt.Errorf("unit result is %s", result)
+	}
+}
+
+func (s *systemd) status() (Status, error) {
 	exitCode, out, err := s.runWithOutput("systemctl", "is-active", s.unitName())
 	if exitCode == 0 && err != nil {
 		return StatusUnknown, err
@@ -269,14 +690,116 @@ func (s *systemd) Status() (Status, error) {
 }
 
 func (s *systemd) Start() error {
+	if s.transient() {
+		args, err := s.transientArgs()
+		if err != nil {
+			return err
+		}
+		return run("systemd-run", args...)
+	}
 	return s.runAction("start")
 }
 
+// transient reports whether the service is started as a transient unit.
+func (s *systemd) transient() bool {
+	return s.Option.bool(optionTransient, optionTransientDefault)
+}
+
+// transientArgs returns the systemd-run arguments starting the service as a
+// transient unit, with the settings of the unit file as properties.
+func (s *systemd) transientArgs() ([]string, error) {
+	restart, err := s.restart()
+	if err != nil {
+		return nil, err
+	}
+	path, err := s.execPath()
+	if err != nil {
+		return nil, err
+	}
+	selinuxContext, err := s.confinementOption(optionSELinuxContext)
+	if err != nil {
+		return nil, err
+	}
+	appArmorProfile, err := s.confinementOption(optionAppArmorProfile)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"--unit=" + s.unitName()}
+	if s.isUserService() {
+		args = append(args, "--user")
+	}
+	if s.Description != "" {
+		args = append(args, "--description="+s.Description)
+	}
+	if s.UserName != "" {
+		args = append(args, "--uid="+s.UserName)
+	}
+	if s.WorkingDirectory != "" {
+		args = append(args, "--working-directory="+s.WorkingDirectory)
+	}
+	property := func(name, value string) {
+		args = append(args, "--property="+name+"="+value)
+	}
+	if s.Option.bool(optionAfterNetworkOnline, optionAfterNetworkOnlineDefault) {
+		property("After", "network-online.target")
+		property("Wants", "network-online.target")
+	}
+	if s.ChRoot != "" {
+		property("RootDirectory", s.ChRoot)
+	}
+	if restart != "" {
+		property("Restart", restart)
+	}
+	if v := s.Option.string(optionSuccessExitStatus, ""); v != "" {
+		property("SuccessExitStatus", v)
+	}
+	if v := s.Option.int(optionLimitNOFILE, optionLimitNOFILEDefault); v > -1 {
+		property("LimitNOFILE", strconv.Itoa(v))
+	}
+	if selinuxContext != "" {
+		property("SELinuxContext", selinuxContext)
+	}
+	if appArmorProfile != "" {
+		property("AppArmorProfile", appArmorProfile)
+	}
+	if s.Option.bool(optionLogOutput, optionLogOutputDefault) && s.hasOutputFileSupport() {
+		logDir := s.Option.string(optionLogDirectory, defaultLogDirectory)
+		property("StandardOutput", "file:"+logDir+"/"+s.Name+".out")
+		property("StandardError", "file:"+logDir+"/"+s.Name+".err")
+	}
+	keys := make([]string, 0, len(s.EnvVars))
+	for k := range s.EnvVars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--setenv="+k+"="+s.EnvVars[k])
+	}
+
+	args = append(args, "--", path)
+	return append(args, s.Arguments...), nil
+}
+
 func (s *systemd) Stop() error {
 	return s.runAction("stop")
 }
 
+func (s *systemd) DisableAndStop() error {
+	if s.transient() {
+		return s.Stop()
+	}
+	return s.run("disable", "--now", s.unitName())
+}
+
 func (s *systemd) Restart() error {
+	if s.transient() {
+		// A transient unit is gone once stopped, start it anew.
+		if err := s.Stop(); err != nil {
+			return err
+		}
+		return s.Start()
+	}
 	return s.runAction("restart")
 }
 
@@ -288,10 +811,14 @@ func (s *systemd) runWithOutput(command string, arguments ...string) (int, strin
 }
 
 func (s *systemd) run(action string, args ...string) error {
+	return run("systemctl", s.systemctlArgs(action, args...)...)
+}
+
+func (s *systemd) systemctlArgs(action string, args ...string) []string {
 	if s.isUserService() {
-		return run("systemctl", append([]string{action, "--user"}, args...)...)
+		return append([]string{action, "--user"}, args...)
 	}
-	return run("systemctl", append([]string{action}, args...)...)
+	return append([]string{action}, args...)
 }
 
 func (s *systemd) runAction(action string) error {
@@ -301,18 +828,28 @@ func (s *systemd) runAction(action string) error {
 const systemdScript = `[Unit]
 Description={{.Description}}
 ConditionFileIsExecutable={{.Path|cmdEscape}}
+{{if .AfterNetworkOnline}}After=network-online.target
+Wants=network-online.target
+{{end -}}
+{{if .StartLimitAction}}StartLimitAction={{.StartLimitAction}}
+{{end -}}
 {{range $i, $dep := .Dependencies}} 
 {{$dep}} {{end}}
 
 [Service]
-StartLimitInterval=5
-StartLimitBurst=10
-ExecStart={{.Path|cmdEscape}}{{range .Arguments}} {{.|cmd}}{{end}}
+StartLimitInterval={{.StartLimitInterval}}
+StartLimitBurst={{.StartLimitBurst}}
+{{if .DropIn}}ExecStart=
+{{end}}ExecStart={{.Path|cmdEscape}}{{range .Arguments}} {{.|cmd}}{{end}}
 {{if .ChRoot}}RootDirectory={{.ChRoot|cmd}}{{end}}
 {{if .WorkingDirectory}}WorkingDirectory={{.WorkingDirectory|cmdEscape}}{{end}}
 {{if .UserName}}User={{.UserName}}{{end}}
 {{if .ReloadSignal}}ExecReload=/bin/kill -{{.ReloadSignal}} "$MAINPID"{{end}}
 {{if .PIDFile}}PIDFile={{.PIDFile|cmd}}{{end}}
+{{if .SELinuxContext}}SELinuxContext={{.SELinuxContext}}{{end}}
+{{if .AppArmorProfile}}AppArmorProfile={{.AppArmorProfile}}{{end}}
+{{if .ConfigDirectory}}ConfigurationDirectory={{.ConfigDirectory}}{{end}}
+{{if .ConfigDirectoryMode}}ConfigurationDirectoryMode={{.ConfigDirectoryMode}}{{end}}
 {{if and .LogOutput .HasOutputFileSupport -}}
 StandardOutput=file:{{.LogDirectory}}/{{.Name}}.out
 StandardError=file:{{.LogDirectory}}/{{.Name}}.err
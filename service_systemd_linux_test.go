@@ -0,0 +1,711 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestSystemdCapabilities(t *testing.T) {
+	tests := []struct {
+		name   string
+		option KeyValue
+		want   Capability
+	}{
+		{"default", nil, CapabilityUserService},
+		{"reload-signal", KeyValue{optionReloadSignal: "HUP"}, CapabilityUserService | CapabilityReload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newSystemdService(nil, "linux-systemd", &Config{Name: "test", Option: tt.option})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Capabilities(s); got != tt.want {
+				t.Errorf("Capabilities() = %b, want %b", got, tt.want)
+			}
+			if !Capabilities(s).Has(CapabilityUserService) {
+				t.Error("Capabilities() is missing CapabilityUserService")
+			}
+		})
+	}
+}
+
+func TestSystemdPreferUserService(t *testing.T) {
+	defer func(f func() bool) { hasRootPrivileges = f }(hasRootPrivileges)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	home, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	os.Setenv("HOME", home)
+
+	tests := []struct {
+		name       string
+		root       bool
+		option     KeyValue
+		wantUser   bool
+		wantConfig string
+	}{
+		{"root", true, KeyValue{optionPreferUserService: true}, false, "/etc/systemd/system/test.service"},
+		{"unprivileged", false, KeyValue{optionPreferUserService: true}, true, filepath.Join(home, ".config/systemd/user/test.service")},
+		{"unprivileged-no-preference", false, nil, false, "/etc/systemd/system/test.service"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hasRootPrivileges = func() bool { return tt.root }
+			s, err := newSystemdService(nil, "linux-systemd", &Config{Name: "test", Option: tt.option})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sd := s.(*systemd)
+			if got := sd.isUserService(); got != tt.wantUser {
+				t.Errorf("isUserService() = %v, want %v", got, tt.wantUser)
+			}
+			cp, err := sd.configPath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cp != tt.wantConfig {
+				t.Errorf("configPath() = %q, want %q", cp, tt.wantConfig)
+			}
+		})
+	}
+}
+
+func TestSystemdRestartPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  KeyValue
+		want    string
+		wantErr bool
+	}{
+		{"default", nil, "always", false},
+		{"policy", KeyValue{optionRestartPolicy: restartPolicyOnFailure}, "on-failure", false},
+		{"policy-no", KeyValue{optionRestartPolicy: restartPolicyNo}, "no", false},
+		{"restart-overrides", KeyValue{optionRestartPolicy: restartPolicyNo, optionRestart: "on-abort"}, "on-abort", false},
+		{"invalid", KeyValue{optionRestartPolicy: "sometimes"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &systemd{Config: &Config{Name: "test", Option: tt.option}}
+			got, err := s.restart()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("restart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("restart() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSystemdRunResult(t *testing.T) {
+	finished := time.Date(2023, 10, 14, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		out     string
+		want    RunResult
+		wantErr bool
+	}{
+		{"success", "ExecMainExitTimestamp=Sat 2023-10-14 10:00:00 UTC\nExecMainStatus=0\nResult=success\n", RunResult{0, finished, true}, false},
+		{"failure", "ExecMainExitTimestamp=Sat 2023-10-14 10:00:00 UTC\nExecMainStatus=3\nResult=exit-code\n", RunResult{3, finished, false}, false},
+		{"never ran", "ExecMainExitTimestamp=\nExecMainStatus=0\nResult=success\n", RunResult{}, false},
+		{"no result", "ExecMainStatus=0\n", RunResult{}, true},
+		{"bad status", "ExecMainStatus=x\nResult=success\n", RunResult{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSystemdRunResult(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSystemdRunResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.ExitCode != tt.want.ExitCode || !got.FinishedAt.Equal(tt.want.FinishedAt) || got.Success != tt.want.Success {
+				t.Errorf("parseSystemdRunResult() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemdDropInOnly(t *testing.T) {
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	home, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	os.Setenv("HOME", home)
+
+	s, err := newSystemdService(nil, "linux-systemd", &Config{
+		Name:   "test",
+		Option: KeyValue{optionUserService: true, optionDropInOnly: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd := s.(*systemd)
+	if err := sd.Install(); err == nil {
+		t.Fatal("Install() without a main unit succeeded, want error")
+	}
+	cp, err := sd.configPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cp); !os.IsNotExist(err) {
+		t.Errorf("Install() created the main unit %s", cp)
+	}
+	dropIn, err := sd.dropInPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := cp + ".d/override.conf"; dropIn != want {
+		t.Errorf("dropInPath() = %q, want %q", dropIn, want)
+	}
+	if _, err := os.Stat(dropIn); !os.IsNotExist(err) {
+		t.Errorf("Install() created the drop-in %s", dropIn)
+	}
+
+	var b bytes.Buffer
+	if err := sd.render(&b, true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\nExecStart=\nExecStart=") {
+		t.Errorf("drop-in does not reset ExecStart:\n%s", b.String())
+	}
+}
+
+func TestSystemdRunNow(t *testing.T) {
+	defer func(dirs []string) { systemdUnitDirs = dirs }(systemdUnitDirs)
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	systemdUnitDirs = []string{dir}
+
+	s := &systemd{Config: &Config{Name: "servicetest-runnow"}}
+	if err := s.RunNow(); err != ErrNotSupported {
+		t.Errorf("RunNow() without a timer error = %v, want %v", err, ErrNotSupported)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, s.timerName()), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !s.hasUnitFile("/nonexistent", s.timerName()) {
+		t.Error("hasUnitFile() did not find the timer")
+	}
+
+	tests := []struct {
+		name        string
+		userService bool
+		want        []string
+	}{
+		{"system", false, []string{"start", "servicetest-runnow.service"}},
+		{"user", true, []string{"start", "--user", "servicetest-runnow.service"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.userService = tt.userService
+			if got := s.systemctlArgs("start", s.unitName()); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("systemctlArgs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemdInstallFileMode(t *testing.T) {
+	defer syscall.Umask(syscall.Umask(077))
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	home, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	os.Setenv("HOME", home)
+
+	tests := []struct {
+		name   string
+		option KeyValue
+		want   os.FileMode
+	}{
+		{"default", nil, 0644},
+		{"file mode", KeyValue{optionFileMode: os.FileMode(0640)}, 0640},
+		{"int", KeyValue{optionFileMode: 0600}, 0600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			option := KeyValue{optionUserService: true}
+			for k, v := range tt.option {
+				option[k] = v
+			}
+			s, err := newSystemdService(nil, "linux-systemd", &Config{Name: "servicetest-mode", Option: option})
+			if err != nil {
+				t.Fatal(err)
+			}
+			cp, err := s.(*systemd).configPath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(cp)
+
+			_, restore := fakeCommandRunner(map[string]fakeResult{
+				"systemctl enable --user servicetest-mode.service": {},
+				"systemctl daemon-reload --user":                   {},
+			})
+			defer restore()
+			if err := s.Install(); err != nil {
+				t.Fatal(err)
+			}
+			fi, err := os.Stat(cp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fi.Mode().Perm(); got != tt.want {
+				t.Errorf("unit file mode = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemdConfinement(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  KeyValue
+		want    []string
+		wantErr bool
+	}{
+		{"unset", nil, nil, false},
+		{"selinux", KeyValue{optionSELinuxContext: "system_u:system_r:app_t:s0"}, []string{"\nSELinuxContext=system_u:system_r:app_t:s0\n"}, false},
+		{"apparmor", KeyValue{optionAppArmorProfile: "app"}, []string{"\nAppArmorProfile=app\n"}, false},
+		{"both", KeyValue{optionSELinuxContext: "app_t", optionAppArmorProfile: "app"}, []string{"\nSELinuxContext=app_t\n", "\nAppArmorProfile=app\n"}, false},
+		{"empty", KeyValue{optionSELinuxContext: ""}, nil, true},
+		{"blank", KeyValue{optionAppArmorProfile: "  "}, nil, true},
+		{"multi-line", KeyValue{optionAppArmorProfile: "app\nUser=root"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &systemd{Config: &Config{Name: "test", Option: tt.option}}
+			var b bytes.Buffer
+			err := s.render(&b, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			unit := b.String()
+			for _, want := range tt.want {
+				if !strings.Contains(unit, want) {
+					t.Errorf("unit is missing %q:\n%s", want, unit)
+				}
+			}
+			if tt.option == nil && (strings.Contains(unit, "SELinuxContext=") || strings.Contains(unit, "AppArmorProfile=")) {
+				t.Errorf("unit sets confinement without the options:\n%s", unit)
+			}
+		})
+	}
+}
+
+func TestSystemdAfterNetworkOnline(t *testing.T) {
+	s := &systemd{Config: &Config{Name: "test", Option: KeyValue{optionAfterNetworkOnline: true}}}
+	var b bytes.Buffer
+	if err := s.render(&b, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\nAfter=network-online.target\nWants=network-online.target\n") {
+		t.Errorf("unit does not order after network-online.target:\n%s", b.String())
+	}
+}
+
+func TestSystemdDisableAndStop(t *testing.T) {
+	calls, restore := fakeCommandRunner(map[string]fakeResult{
+		"systemctl disable --now test.service":        {},
+		"systemctl disable --user --now test.service": {},
+	})
+	defer restore()
+
+	s := &systemd{Config: &Config{Name: "test"}}
+	if err := s.DisableAndStop(); err != nil {
+		t.Fatal(err)
+	}
+	s.userService = true
+	if err := s.DisableAndStop(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*calls) != 2 {
+		t.Errorf("DisableAndStop() ran %q, want one systemctl disable --now each", *calls)
+	}
+}
+
+func TestSystemdStartLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  KeyValue
+		unit    string
+		service []string
+		wantErr bool
+	}{
+		{"default", nil, "", []string{"\nStartLimitInterval=5\n", "\nStartLimitBurst=10\n"}, false},
+		{"reboot", KeyValue{optionStartLimitAction: "reboot", optionStartLimitInterval: time.Minute, optionStartLimitBurst: 3},
+			"\nStartLimitAction=reboot\n", []string{"\nStartLimitInterval=60\n", "\nStartLimitBurst=3\n"}, false},
+		{"sub-second interval", KeyValue{optionStartLimitInterval: 1500 * time.Millisecond}, "", []string{"\nStartLimitInterval=1.5s\n"}, false},
+		{"invalid action", KeyValue{optionStartLimitAction: "explode"}, "", nil, true},
+		{"negative burst", KeyValue{optionStartLimitBurst: -1}, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &systemd{Config: &Config{Name: "test", Option: tt.option}}
+			var b bytes.Buffer
+			err := s.render(&b, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			parts := strings.SplitN(b.String(), "[Service]", 2)
+			if len(parts) != 2 {
+				t.Fatalf("unit has no [Service] section:\n%s", b.String())
+			}
+			unitSection, serviceSection := parts[0], parts[1]
+			if tt.unit == "" && strings.Contains(unitSection, "StartLimitAction=") {
+				t.Errorf("[Unit] sets StartLimitAction without the option:\n%s", unitSection)
+			}
+			if tt.unit != "" && !strings.Contains(unitSection, tt.unit) {
+				t.Errorf("[Unit] is missing %q:\n%s", tt.unit, unitSection)
+			}
+			for _, want := range tt.service {
+				if !strings.Contains(serviceSection, want) {
+					t.Errorf("[Service] is missing %q:\n%s", want, serviceSection)
+				}
+			}
+		})
+	}
+}
+
+func TestSystemdConfigDirectory(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  KeyValue
+		want    []string
+		wantErr bool
+	}{
+		{"unset", nil, nil, false},
+		{"directory", KeyValue{optionConfigDirectory: "app"}, []string{"\nConfigurationDirectory=app\n"}, false},
+		{"mode", KeyValue{optionConfigDirectory: "app/conf", optionConfigurationDirectoryMode: os.FileMode(0750)},
+			[]string{"\nConfigurationDirectory=app/conf\n", "\nConfigurationDirectoryMode=0750\n"}, false},
+		{"int mode", KeyValue{optionConfigDirectory: "app", optionConfigurationDirectoryMode: 0700},
+			[]string{"\nConfigurationDirectoryMode=0700\n"}, false},
+		{"absolute", KeyValue{optionConfigDirectory: "/etc/app"}, nil, true},
+		{"multi-line", KeyValue{optionConfigDirectory: "app\nUser=root"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, restore := fakeCommandRunner(map[string]fakeResult{"systemctl --version": {stdout: "systemd 249 (249.11)\n"}})
+			resetSystemdVersion()
+			defer resetSystemdVersion()
+			defer restore()
+
+			s := &systemd{Config: &Config{Name: "test", Option: tt.option}}
+			var b bytes.Buffer
+			err := s.render(&b, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			unit := b.String()
+			for _, want := range tt.want {
+				if !strings.Contains(unit, want) {
+					t.Errorf("unit is missing %q:\n%s", want, unit)
+				}
+			}
+			if tt.option == nil && strings.Contains(unit, "ConfigurationDirectory") {
+				t.Errorf("unit sets ConfigurationDirectory without the option:\n%s", unit)
+			}
+		})
+	}
+}
+
+func TestSystemdConfigDirectoryVersion(t *testing.T) {
+	for _, tt := range []struct {
+		version string
+		want    bool
+	}{
+		{"systemd 234\n", false},
+		{"systemd 235\n", true},
+		{"systemd 252 (252.19-1)\n", true},
+	} {
+		_, restore := fakeCommandRunner(map[string]fakeResult{"systemctl --version": {stdout: tt.version}})
+		resetSystemdVersion()
+		s := &systemd{Config: &Config{Name: "test", Option: KeyValue{
+			optionConfigDirectory:            "app",
+			optionConfigurationDirectoryMode: 0750,
+		}}}
+		var b bytes.Buffer
+		err := s.render(&b, false)
+		restore()
+		resetSystemdVersion()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(b.String(), "ConfigurationDirectory"); got != tt.want {
+			t.Errorf("%q: unit sets ConfigurationDirectory = %v, want %v:\n%s", tt.version, got, tt.want, b.String())
+		}
+	}
+}
+
+func TestSystemdInstallRestoreCon(t *testing.T) {
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	home, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	os.Setenv("HOME", home)
+
+	for _, restoreCon := range []bool{true, false} {
+		s := &systemd{userService: true, Config: &Config{Name: "servicetest-restorecon", Option: KeyValue{
+			optionRestoreCon: restoreCon,
+		}}}
+		cp, err := s.configPath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls, restore := fakeCommandRunner(map[string]fakeResult{
+			"restorecon " + cp: {},
+			"systemctl enable --user servicetest-restorecon.service": {},
+			"systemctl daemon-reload --user":                         {},
+		})
+		err = s.Install()
+		restore()
+		os.Remove(cp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "systemctl enable --user servicetest-restorecon.service,systemctl daemon-reload --user"
+		if restoreCon {
+			want = "restorecon " + cp + "," + want
+		}
+		if got := strings.Join(*calls, ","); !strings.HasSuffix(got, want) {
+			t.Errorf("RestoreCon=%v: Install ran %q, want %q", restoreCon, got, want)
+		}
+	}
+}
+
+func TestSystemdHealthCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		check fakeResult
+		want  ServiceStatus
+	}{
+		{"healthy", fakeResult{}, ServiceStatus{State: StatusRunning, PID: 42}},
+		{"degraded", fakeResult{1, ""}, ServiceStatus{State: StatusRunning, PID: 42, Degraded: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, restore := fakeCommandRunner(map[string]fakeResult{
+				"systemctl is-active test.service":                                   {stdout: "active\n"},
+				"systemctl show test.service -p MainPID,StateChangeTimestamp,Result": {stdout: "MainPID=42\nResult=success\n"},
+				"/bin/sh -c check-app":                                               tt.check,
+			})
+			defer restore()
+			s := &systemd{Config: &Config{Name: "test", Option: KeyValue{optionHealthCheckCommand: "check-app"}}}
+
+			if got, err := s.Status(); got != StatusRunning || err != nil {
+				t.Errorf("Status() = %v, %v, want the unit state %v", got, err, StatusRunning)
+			}
+			if got := strings.Join(*calls, ","); got != "systemctl is-active test.service" {
+				t.Errorf("Status() ran %q, want only systemctl is-active", got)
+			}
+			if got := CombinedStatus(s); got != tt.want {
+				t.Errorf("CombinedStatus() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemdLogs(t *testing.T) {
+	calls, restore := fakeCommandRunner(map[string]fakeResult{
+		"journalctl --unit=test.service --lines=5 --no-pager":      {stdout: "system\n"},
+		"journalctl --user-unit=test.service --lines=5 --no-pager": {stdout: "user\n"},
+	})
+	defer restore()
+
+	for _, tt := range []struct {
+		userService bool
+		want        string
+	}{
+		{false, "system\n"},
+		{true, "user\n"},
+	} {
+		s := &systemd{userService: tt.userService, Config: &Config{Name: "test"}}
+		got, err := s.Logs(5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Logs() = %q, want %q, ran %q", got, tt.want, *calls)
+		}
+	}
+	if _, err := (&systemd{Config: &Config{Name: "test"}}).Logs(0); err == nil {
+		t.Error("Logs(0) succeeded, want an error")
+	}
+}
+
+func TestParseSystemdProperties(t *testing.T) {
+	const out = `Type=simple
+Restart=always
+MainPID=4242
+ExecStart={ path=/usr/bin/app ; argv[]=/usr/bin/app -c /etc/app.conf ; ignore_errors=no }
+Environment=A=1 B=2
+LoadState=loaded
+ActiveState=active
+Description=
+`
+	got := parseSystemdProperties(out)
+	want := map[string]string{
+		"Type":        "simple",
+		"Restart":     "always",
+		"MainPID":     "4242",
+		"ExecStart":   "{ path=/usr/bin/app ; argv[]=/usr/bin/app -c /etc/app.conf ; ignore_errors=no }",
+		"Environment": "A=1 B=2",
+		"LoadState":   "loaded",
+		"ActiveState": "active",
+		"Description": "",
+	}
+	if len(got) != len(want) {
+		t.Errorf("parseSystemdProperties() has %d properties, want %d: %q", len(got), len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestSystemdPropertiesNotInstalled(t *testing.T) {
+	_, restore := fakeCommandRunner(map[string]fakeResult{
+		"systemctl show test.service": {stdout: "Id=test.service\nLoadState=not-found\n"},
+	})
+	defer restore()
+
+	if _, err := (&systemd{Config: &Config{Name: "test"}}).Properties(); err != ErrNotInstalled {
+		t.Errorf("Properties() error = %v, want %v", err, ErrNotInstalled)
+	}
+}
+
+func TestSystemdTransient(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:             "test",
+		Description:      "Test job",
+		UserName:         "app",
+		WorkingDirectory: "/srv/app",
+		Executable:       "/usr/bin/app",
+		Arguments:        []string{"-c", "/etc/app.conf"},
+		EnvVars:          map[string]string{"B": "2", "A": "1"},
+		Option: KeyValue{
+			optionTransient:          true,
+			optionRestartPolicy:      restartPolicyOnFailure,
+			optionLimitNOFILE:        1024,
+			optionAfterNetworkOnline: true,
+		},
+	}}
+
+	want := "systemd-run --unit=test.service --description=Test job --uid=app --working-directory=/srv/app" +
+		" --property=After=network-online.target --property=Wants=network-online.target" +
+		" --property=Restart=on-failure --property=LimitNOFILE=1024" +
+		" --setenv=A=1 --setenv=B=2 -- /usr/bin/app -c /etc/app.conf"
+	calls, restore := fakeCommandRunner(map[string]fakeResult{
+		want:                          {},
+		"systemctl stop test.service": {},
+	})
+	defer restore()
+
+	// Nothing is written, so nothing is run.
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if len(*calls) != 0 {
+		t.Fatalf("Install and Uninstall ran %q, want nothing", *calls)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(*calls, ","); got != want+",systemctl stop test.service" {
+		t.Errorf("Start and Stop ran %q, want %q", got, want+",systemctl stop test.service")
+	}
+}
+
+func TestParseSystemdStatus(t *testing.T) {
+	since := time.Date(2024, 3, 5, 10, 30, 0, 0, time.Local)
+	tests := []struct {
+		name string
+		out  string
+		want ServiceStatus
+	}{
+		{"running", "MainPID=812\nStateChangeTimestamp=" + since.Format(systemdTimestampLayout) + "\nResult=success\n",
+			ServiceStatus{State: StatusRunning, PID: 812, Since: since}},
+		{"failed", "MainPID=0\nStateChangeTimestamp=n/a\nResult=exit-code\n",
+			ServiceStatus{State: StatusUnknown, LastError: errors.New("unit result is exit-code")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ServiceStatus{State: tt.want.State}
+			parseSystemdStatus(tt.out, &got)
+			if got.PID != tt.want.PID || !got.Since.Equal(tt.want.Since) {
+				t.Errorf("parseSystemdStatus() = %+v, want %+v", got, tt.want)
+			}
+			if fmt.Sprint(got.LastError) != fmt.Sprint(tt.want.LastError) {
+				t.Errorf("LastError = %v, want %v", got.LastError, tt.want.LastError)
+			}
+		})
+	}
+}
+
+// resetSystemdVersion forgets the cached systemd version, for tests faking
+// systemctl --version.
+func resetSystemdVersion() {
+	systemdVersionOnce = new(sync.Once)
+}
+
+func TestSystemdVersionCached(t *testing.T) {
+	calls, restore := fakeCommandRunner(map[string]fakeResult{"systemctl --version": {stdout: "systemd 252\n"}})
+	resetSystemdVersion()
+	defer resetSystemdVersion()
+	defer restore()
+
+	for _, name := range []string{"app1", "app2", "app3"} {
+		s := &systemd{Config: &Config{Name: name, Option: KeyValue{
+			optionLogOutput:       true,
+			optionConfigDirectory: name,
+		}}}
+		var b bytes.Buffer
+		if err := s.render(&b, false); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(b.String(), "StandardOutput=file:") || !strings.Contains(b.String(), "ConfigurationDirectory="+name) {
+			t.Errorf("unit of %s does not use the systemd 252 settings:\n%s", name, b.String())
+		}
+	}
+	if n := len(*calls); n != 1 {
+		t.Errorf("ran systemctl --version %d times, want 1: %q", n, *calls)
+	}
+}
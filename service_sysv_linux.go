@@ -5,10 +5,16 @@
 package service
 
 import (
-	"errors"
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
-	"os/signal"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"syscall"
 	"text/template"
@@ -18,6 +24,8 @@ import (
 type sysv struct {
 	i        Interface
 	platform string
+	tmpl     *template.Template
+	loggers  loggerSet
 	*Config
 }
 
@@ -27,6 +35,9 @@ func newSystemVService(i Interface, platform string, c *Config) (Service, error)
 		platform: platform,
 		Config:   c,
 	}
+	if _, err := s.template(); err != nil {
+		return nil, err
+	}
 
 	return s, nil
 }
@@ -42,90 +53,1004 @@ func (s *sysv) Platform() string {
 	return s.platform
 }
 
-var errNoUserServiceSystemV = errors.New("User services are not supported on SystemV.")
+var errNoUserServiceSystemV = notSupported("user service", "System V")
+
+// backupTimeFormat is the suffix of an init script kept by BackupOnInstall.
+const backupTimeFormat = "20060102150405"
+
+func (s *sysv) Capabilities() Capability {
+	if s.Option.string(optionReloadCommand, "") != "" || s.Option.string(optionReloadSignal, "") != "" {
+		return CapabilityReload
+	}
+	return 0
+}
 
 func (s *sysv) configPath() (cp string, err error) {
 	if s.Option.bool(optionUserService, optionUserServiceDefault) {
 		err = errNoUserServiceSystemV
 		return
 	}
-	cp = "/etc/init.d/" + s.Config.Name
+	if prefix := s.Option.string(optionRootPrefix, ""); prefix != "" && !filepath.IsAbs(prefix) {
+		err = fmt.Errorf("%s %q must be an absolute path", optionRootPrefix, prefix)
+		return
+	}
+	dir, err := initDir(s.Option)
+	if err != nil {
+		return
+	}
+	cp = filepath.Join(dir, s.Config.Name)
 	return
 }
 
-func (s *sysv) template() *template.Template {
-	customScript := s.Option.string(optionSysvScript, "")
+// staged returns path under RootPrefix, path itself if there is none.
+func (s *sysv) staged(path string) string {
+	return filepath.Join(s.Option.string(optionRootPrefix, ""), path)
+}
 
-	if customScript != "" {
-		return template.Must(template.New("").Funcs(tf).Parse(customScript))
+// serviceCommand returns the command running action of the init script,
+// ServiceCommand for a script in the default InitDir and the script itself
+// otherwise or with PreferInitScript.
+func (s *sysv) serviceCommand(action string) (string, []string, error) {
+	cp, err := s.configPath()
+	if err != nil {
+		return "", nil, err
+	}
+	if filepath.Dir(cp) == optionInitDirDefault && !s.Option.bool(optionPreferInitScript, false) {
+		return s.Option.string(optionServiceCommand, optionServiceCommandDefault), []string{s.Name, action}, nil
 	}
-	return template.Must(template.New("").Funcs(tf).Parse(sysvScript))
+	return cp, []string{action}, nil
 }
 
-func (s *sysv) Install() error {
+// runServiceCommand runs the serviceCommand of action. If there is no service
+// command the init script is run directly, a ServiceCommand that is not found
+// is an error.
+func (s *sysv) runServiceCommand(ctx context.Context, action string, readStdout bool) (int, string, error) {
+	command, args, err := s.serviceCommand(action)
+	if err != nil {
+		return 0, "", err
+	}
+	exitCode, out, err := runCommandContext(ctx, command, readStdout, args...)
+	if command == optionServiceCommandDefault && isNotFound(err) {
+		cp, _ := s.configPath()
+		return runCommandContext(ctx, cp, readStdout, action)
+	}
+	return exitCode, out, err
+}
+
+// isNotFound reports whether err is a CommandError for a command that is not
+// in PATH.
+func isNotFound(err error) bool {
+	cerr, ok := err.(*CommandError)
+	if !ok {
+		return false
+	}
+	eerr, ok := cerr.Err.(*exec.Error)
+	return ok && eerr.Err == exec.ErrNotFound
+}
+
+// pidDirStat stats the candidate pid directories. Tests replace it.
+var pidDirStat = os.Stat
+
+// pidDir returns /run, or the older /var/run on a system without /run.
+func pidDir() string {
+	if fi, err := pidDirStat("/run"); err == nil && fi.IsDir() {
+		return "/run"
+	}
+	return "/var/run"
+}
+
+func (s *sysv) pidFile() string {
+	return s.Option.string(optionPIDFile, filepath.Join(pidDir(), s.Name+".pid"))
+}
+
+// EffectiveConfig returns every option the System V backend reads, with
+// defaults applied. RunWait is left out as it is a function.
+func (s *sysv) EffectiveConfig() map[string]interface{} {
+	return map[string]interface{}{
+		optionUserService:         s.Option.bool(optionUserService, optionUserServiceDefault),
+		optionSysvScript:          s.Option.string(optionSysvScript, ""),
+		optionSysvTemplate:        s.sysvTemplate(),
+		optionTemplateData:        s.templateData(),
+		optionPIDFile:             s.pidFile(),
+		optionLogDirectory:        s.Option.string(optionLogDirectory, defaultLogDirectory),
+		optionProcessName:         s.Option.string(optionProcessName, ""),
+		optionSingleInstance:      s.Option.bool(optionSingleInstance, optionSingleInstanceDefault),
+		optionEnabled:             s.Option.bool(optionEnabled, optionEnabledDefault),
+		optionSysVStartBefore:     s.Option.string(optionSysVStartBefore, ""),
+		optionSysVStopAfter:       s.Option.string(optionSysVStopAfter, ""),
+		optionReloadSignal:        s.Option.string(optionReloadSignal, ""),
+		optionReloadCommand:       s.Option.string(optionReloadCommand, ""),
+		optionSystemLoggerBackend: s.Option.string(optionSystemLoggerBackend, systemLoggerSyslog),
+		optionSyslogFacility:      s.Option.string(optionSyslogFacility, ""),
+		optionSyslogTag:           s.Option.string(optionSyslogTag, s.Name),
+		optionAfterNetworkOnline:  s.Option.bool(optionAfterNetworkOnline, optionAfterNetworkOnlineDefault),
+		optionForceKill:           s.Option.bool(optionForceKill, optionForceKillDefault),
+		optionUMask:               s.Option.string(optionUMask, ""),
+		optionNice:                s.Option.int(optionNice, 0),
+		optionIONiceClass:         s.Option.int(optionIONiceClass, 0),
+		optionIONicePriority:      s.Option.int(optionIONicePriority, 0),
+		optionInitDir:             s.Option.string(optionInitDir, optionInitDirDefault),
+		optionStatusTimeout:       s.Option.duration(optionStatusTimeout, optionStatusTimeoutDefault),
+		optionExpandArgEnv:        s.Option.bool(optionExpandArgEnv, optionExpandArgEnvDefault),
+		optionConditionPathExists: s.Option.string(optionConditionPathExists, ""),
+		optionSkipExecCheck:       s.Option.bool(optionSkipExecCheck, optionSkipExecCheckDefault),
+		optionBackupOnInstall:     s.Option.bool(optionBackupOnInstall, optionBackupOnInstallDefault),
+		optionRestoreOnUninstall:  s.Option.bool(optionRestoreOnUninstall, optionRestoreOnUninstallDefault),
+		optionStartAfterInstall:   s.Option.bool(optionStartAfterInstall, optionStartAfterInstallDefault),
+		optionStopBeforeUninstall: s.Option.bool(optionStopBeforeUninstall, optionStopBeforeUninstallDefault),
+		optionEnvFile:             s.Option.string(optionEnvFile, ""),
+		optionSysVStartPriority:   s.Option.int(optionSysVStartPriority, optionSysVStartPriorityDefault),
+		optionSysVKillPriority:    s.Option.int(optionSysVKillPriority, optionSysVKillPriorityDefault),
+		optionStartRunlevels:      s.Option.string(optionStartRunlevels, optionStartRunlevelsDefault),
+		optionStopRunlevels:       s.Option.string(optionStopRunlevels, optionStopRunlevelsDefault),
+		optionCombinedOutput:      s.Option.bool(optionCombinedOutput, optionCombinedOutputDefault),
+		optionStartRetries:        s.Option.int(optionStartRetries, optionStartRetriesDefault),
+		optionForking:             s.Option.bool(optionForking, optionForkingDefault),
+		optionRootPrefix:          s.Option.string(optionRootPrefix, ""),
+		optionShell:               s.Option.string(optionShell, optionShellDefault),
+		optionPreferInitScript:    s.Option.bool(optionPreferInitScript, false),
+		optionServiceCommand:      s.Option.string(optionServiceCommand, optionServiceCommandDefault),
+		optionArgsFile:            s.Option.string(optionArgsFile, ""),
+		optionMonitIntegration:    s.Option.bool(optionMonitIntegration, false),
+		optionMonitDir:            s.Option.string(optionMonitDir, optionMonitDirDefault),
+		optionLogMaxSize:          s.Option.int(optionLogMaxSize, 0),
+		optionStopTimeout:         s.Option.duration(optionStopTimeout, optionStopTimeoutDefault),
+		optionStartRetryDelay:     s.Option.duration(optionStartRetryDelay, optionStartRetryDelayDefault),
+		optionRestartDelay:        s.Option.duration(optionRestartDelay, optionRestartDelayDefault),
+		optionRestartPolicy:       s.Option.string(optionRestartPolicy, ""),
+		optionRestartSec:          s.Option.duration(optionRestartSec, optionRestartSecDefault),
+		optionReapChildren:        s.Option.bool(optionReapChildren, optionReapChildrenDefault),
+		optionHealthCheckCommand:  s.Option.string(optionHealthCheckCommand, ""),
+		optionFileMode:            fileMode(s.Option, 0755),
+	}
+}
+
+// template returns the init script template, SysvScript if set. It is parsed
+// once, newSystemVService reports a malformed SysvScript.
+func (s *sysv) template() (*template.Template, error) {
+	if s.tmpl != nil {
+		return s.tmpl, nil
+	}
+	if v, found := s.Option[optionSysvTemplate]; found {
+		t := s.sysvTemplate()
+		if t == nil {
+			return nil, fmt.Errorf("invalid %s of type %T, want a *template.Template", optionSysvTemplate, v)
+		}
+		s.tmpl = t
+		return t, nil
+	}
+	script := sysvScript
+	if customScript := s.Option.string(optionSysvScript, ""); customScript != "" {
+		script = customScript
+	}
+	t, err := template.New("").Funcs(tf).Parse(script)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", optionSysvScript, err)
+	}
+	s.tmpl = t
+	return t, nil
+}
+
+func (s *sysv) Install() (err error) {
+	defer observe("install", time.Now(), &err)
+	if err := validateName(s.Name); err != nil {
+		return err
+	}
+	staging := s.Option.string(optionRootPrefix, "") != ""
+	if err := s.checkExec(); err != nil {
+		return err
+	}
+	if err := s.checkFileMode(); err != nil {
+		return err
+	}
+
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
 	}
+	confPath = s.staged(confPath)
+	// A template that fails part way must not leave a truncated init script.
+	if err := s.render(ioutil.Discard); err != nil {
+		return err
+	}
+	if s.Option.bool(optionMonitIntegration, false) {
+		if err := s.renderMonit(ioutil.Discard); err != nil {
+			return err
+		}
+	}
 	_, err = os.Stat(confPath)
 	if err == nil {
-		return fmt.Errorf("Init already exists: %s", confPath)
+		if !s.Option.bool(optionBackupOnInstall, optionBackupOnInstallDefault) {
+			return fmt.Errorf("Init already exists: %s", confPath)
+		}
+		if err := os.Rename(confPath, confPath+".bak-"+time.Now().Format(backupTimeFormat)); err != nil {
+			return err
+		}
 	}
 
 	f, err := os.Create(confPath)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
+	err = s.render(f)
+	// Closed before the script can be started, Linux refuses to exec a file
+	// that is open for writing.
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.Chmod(confPath, fileMode(s.Option, 0755)); err != nil {
+		return err
+	}
+	if err = s.writeArgsFile(); err != nil {
+		return err
+	}
+	if err = s.writeMonit(); err != nil {
+		return err
+	}
+	if s.Option.bool(optionEnabled, optionEnabledDefault) {
+		if err = s.Enable(); err != nil {
+			return fmt.Errorf("init script %s installed but not enabled: %v", confPath, err)
+		}
+	}
+	if staging || !s.Option.bool(optionStartAfterInstall, optionStartAfterInstallDefault) {
+		return nil
+	}
+	return s.Start()
+}
+
+// sysvSyntaxErrorRe matches the line number in a syntax error of sh -n,
+// such as "sh: 12: Syntax error" of dash or "bash: line 12: syntax error".
+var sysvSyntaxErrorRe = regexp.MustCompile(`^[^:]*: (?:line )?(\d+): `)
 
+// Verify renders the init script and checks it with Shell -n, which parses
+// the script without running it, and with shellcheck if it is installed.
+// Only shellcheck errors fail, its warnings do not.
+func (s *sysv) Verify() error {
+	var b bytes.Buffer
+	if err := s.render(&b); err != nil {
+		return err
+	}
+	shell := s.Option.string(optionShell, optionShellDefault)
+	cmd := exec.Command(shell, "-n")
+	cmd.Stdin = bytes.NewReader(b.Bytes())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			return fmt.Errorf("init script does not parse: %v", err)
+		}
+		if m := sysvSyntaxErrorRe.FindStringSubmatch(msg); m != nil {
+			lines := strings.Split(b.String(), "\n")
+			if n, _ := strconv.Atoi(m[1]); n > 0 && n <= len(lines) {
+				return fmt.Errorf("init script does not parse: %s\n%5d | %s", msg, n, lines[n-1])
+			}
+		}
+		return fmt.Errorf("init script does not parse: %s", msg)
+	}
+
+	shellcheck, err := exec.LookPath("shellcheck")
+	if err != nil {
+		return nil
+	}
+	args := []string{"--severity=error"}
+	switch name := filepath.Base(shell); name {
+	case "sh", "bash", "dash", "ksh":
+		args = append(args, "--shell="+name)
+	}
+	cmd = exec.Command(shellcheck, append(args, "-")...)
+	cmd.Stdin = bytes.NewReader(b.Bytes())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("shellcheck of the init script: %v\n%s", err, out)
+	}
+	return nil
+}
+
+// InstallPlan returns the init script and runlevel symlinks Install would
+// write. The timestamp of a backup is the one Install would use now.
+func (s *sysv) InstallPlan() ([]FileOp, error) {
+	if err := validateName(s.Name); err != nil {
+		return nil, err
+	}
+	if err := s.checkExec(); err != nil {
+		return nil, err
+	}
+	if err := s.checkFileMode(); err != nil {
+		return nil, err
+	}
+	livePath, err := s.configPath()
+	if err != nil {
+		return nil, err
+	}
+	confPath := s.staged(livePath)
+	var ops []FileOp
+	if _, err := os.Stat(confPath); err == nil {
+		if !s.Option.bool(optionBackupOnInstall, optionBackupOnInstallDefault) {
+			return nil, fmt.Errorf("Init already exists: %s", confPath)
+		}
+		ops = append(ops, FileOp{Path: confPath, Action: FileRename, Target: confPath + ".bak-" + time.Now().Format(backupTimeFormat)})
+	}
+	if err := s.render(ioutil.Discard); err != nil {
+		return nil, err
+	}
+	ops = append(ops, FileOp{Path: confPath, Action: FileCreate})
+	if argsFile := s.Option.string(optionArgsFile, ""); argsFile != "" {
+		ops = append(ops, FileOp{Path: s.staged(argsFile), Action: FileCreate})
+	}
+	if s.Option.bool(optionMonitIntegration, false) {
+		if err := s.renderMonit(ioutil.Discard); err != nil {
+			return nil, err
+		}
+		ops = append(ops, FileOp{Path: s.staged(s.monitPath()), Action: FileCreate})
+	}
+
+	if !s.Option.bool(optionEnabled, optionEnabledDefault) {
+		return ops, nil
+	}
+	links, err := s.rcLinks(livePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		link = s.staged(link)
+		if _, err := os.Stat(filepath.Dir(link)); err != nil {
+			continue
+		}
+		if _, err := os.Lstat(link); err == nil {
+			continue
+		}
+		ops = append(ops, FileOp{Path: link, Action: FileSymlink, Target: livePath})
+	}
+	return ops, nil
+}
+
+// checkExec checks ChRoot is a directory and, unless SkipExecCheck is set, the
+// executable can be run. Neither is checked if the install is staged under
+// RootPrefix.
+func (s *sysv) checkExec() error {
+	if s.Option.string(optionRootPrefix, "") != "" {
+		return nil
+	}
+	if s.ChRoot != "" {
+		fi, err := os.Stat(s.ChRoot)
+		if err != nil {
+			return fmt.Errorf("chroot: %v", err)
+		}
+		if !fi.IsDir() || !filepath.IsAbs(s.ChRoot) {
+			return fmt.Errorf("chroot %s is not an absolute directory", s.ChRoot)
+		}
+	}
+	if s.Option.bool(optionSkipExecCheck, optionSkipExecCheckDefault) {
+		return nil
+	}
 	path, err := s.execPath()
 	if err != nil {
 		return err
 	}
+	// The executable is looked up in ChRoot when the service starts.
+	return checkExecutable(filepath.Join(s.ChRoot, path), s.UserName)
+}
+
+// checkFileMode returns an error if FileMode would leave the init script
+// unreadable or not executable by its owner.
+func (s *sysv) checkFileMode() error {
+	if mode := fileMode(s.Option, 0755); mode&0500 != 0500 {
+		return fmt.Errorf("invalid %s %v, the init script must be readable and executable by its owner", optionFileMode, mode)
+	}
+	return nil
+}
+
+// Reinstall renders the init script to a temporary file next to the installed
+// one and renames it into place, so the previous script stays installed if
+// anything fails. The runlevel symlinks are then refreshed.
+func (s *sysv) Reinstall() error {
+	if err := validateName(s.Name); err != nil {
+		return err
+	}
+	if installed, err := s.Installed(); err != nil {
+		return err
+	} else if !installed {
+		return s.Install()
+	}
+	if err := s.checkExec(); err != nil {
+		return err
+	}
+	if err := s.checkFileMode(); err != nil {
+		return err
+	}
+
+	livePath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	confPath := s.staged(livePath)
+	f, err := ioutil.TempFile(filepath.Dir(confPath), "."+s.Name+".")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+	defer os.Remove(tmpPath)
+	err = s.render(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpPath, fileMode(s.Option, 0755)); err != nil {
+		return err
+	}
+	if err = s.writeArgsFile(); err != nil {
+		return err
+	}
+	if err = s.writeMonit(); err != nil {
+		return err
+	}
+	if s.Option.bool(optionBackupOnInstall, optionBackupOnInstallDefault) {
+		if err = os.Link(confPath, confPath+".bak-"+time.Now().Format(backupTimeFormat)); err != nil {
+			return err
+		}
+	}
+	if err = os.Rename(tmpPath, confPath); err != nil {
+		return err
+	}
+
+	if s.Option.bool(optionEnabled, optionEnabledDefault) {
+		return s.Enable()
+	}
+	return s.Disable()
+}
+
+// writeArgsFile writes Arguments to ArgsFile, if set, quoted as in the init
+// script and joined with line continuations so the script can eval them.
+func (s *sysv) writeArgsFile() error {
+	argsFile := s.Option.string(optionArgsFile, "")
+	if argsFile == "" {
+		return nil
+	}
+	quote := tf["cmd"].(func(string) string)
+	if s.Option.bool(optionExpandArgEnv, optionExpandArgEnvDefault) {
+		quote = tf["cmdExpand"].(func(string) string)
+	}
+	var b bytes.Buffer
+	for i, arg := range s.Arguments {
+		if i > 0 {
+			b.WriteString(" \\\n")
+		}
+		b.WriteString(quote(arg))
+	}
+	b.WriteByte('\n')
+	// The mode of the init script without its exec bits, so that a FileMode
+	// hiding the script hides the arguments too.
+	mode := fileMode(s.Option, 0755) &^ 0111
+	path := s.staged(argsFile)
+	if err := ioutil.WriteFile(path, b.Bytes(), mode); err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
+
+// monitPath returns the path of the monit fragment of MonitIntegration.
+func (s *sysv) monitPath() string {
+	return filepath.Join(s.Option.string(optionMonitDir, optionMonitDirDefault), s.Name+".conf")
+}
+
+// writeMonit writes the monit fragment if MonitIntegration is set.
+func (s *sysv) writeMonit() error {
+	if !s.Option.bool(optionMonitIntegration, false) {
+		return nil
+	}
+	var b bytes.Buffer
+	if err := s.renderMonit(&b); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.staged(s.monitPath()), b.Bytes(), 0644)
+}
+
+// renderMonit writes the monit fragment that checks the service to w.
+func (s *sysv) renderMonit(w io.Writer) error {
+	confPath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	// monit has no escapes in its double quoted strings.
+	pidFile := s.pidFile()
+	if strings.ContainsAny(confPath+pidFile, "\"\r\n") || strings.ContainsAny(confPath, " \t") {
+		return fmt.Errorf("%s can not quote the paths %q and %q", optionMonitIntegration, confPath, pidFile)
+	}
+	return monitTemplate.Execute(w, struct {
+		Name    string
+		PIDFile string
+		Script  string
+	}{s.Name, pidFile, confPath})
+}
+
+// logDirectory returns LogDirectory, a relative path is resolved against
+// WorkingDirectory as the init script does not run from a known directory.
+func (s *sysv) logDirectory() (string, error) {
+	logDir := s.Option.string(optionLogDirectory, defaultLogDirectory)
+	if filepath.IsAbs(logDir) {
+		return logDir, nil
+	}
+	if s.WorkingDirectory == "" {
+		return "", fmt.Errorf("relative %s %q requires WorkingDirectory", optionLogDirectory, logDir)
+	}
+	return filepath.Join(s.WorkingDirectory, logDir), nil
+}
+
+// render writes the init script to w.
+func (s *sysv) render(w io.Writer) error {
+	path, err := s.execPath()
+	if err != nil {
+		return err
+	}
+
+	logDir, err := s.logDirectory()
+	if err != nil {
+		return err
+	}
+
+	policy, err := restartPolicy(s.Option)
+	if err != nil {
+		return err
+	}
+	if policy == restartPolicyNo {
+		policy = ""
+	}
+	if policy != "" && s.Option.bool(optionSingleInstance, optionSingleInstanceDefault) {
+		// Run would replace the supervisor pid in PIDFile with its own.
+		return fmt.Errorf("%s %s can not be combined with %s on System V", optionRestartPolicy, policy, optionSingleInstance)
+	}
+	forking := s.Option.bool(optionForking, optionForkingDefault)
+	if policy != "" && forking {
+		// The supervisor can only wait for a process that does not fork.
+		return fmt.Errorf("%s %s can not be combined with %s on System V", optionRestartPolicy, policy, optionForking)
+	}
+
+	umask := s.Option.string(optionUMask, "")
+	if umask != "" {
+		if m, err := strconv.ParseUint(umask, 8, 32); err != nil || len(umask) < 3 || len(umask) > 4 || m > 0777 {
+			return fmt.Errorf("invalid %s %q, want an octal umask such as 027", optionUMask, umask)
+		}
+	}
+
+	startLevels, stopLevels, err := s.runlevels()
+	if err != nil {
+		return err
+	}
+
+	nice, ioniceClass, ionicePriority, err := s.nice()
+	if err != nil {
+		return err
+	}
+
+	startPriority, err := sysvPriority(s.Option, optionSysVStartPriority, optionSysVStartPriorityDefault)
+	if err != nil {
+		return err
+	}
+	killPriority, err := sysvPriority(s.Option, optionSysVKillPriority, optionSysVKillPriorityDefault)
+	if err != nil {
+		return err
+	}
+
+	if v, found := s.Option[optionTemplateData]; found && s.templateData() == nil && v != nil {
+		return fmt.Errorf("invalid %s of type %T, want a map[string]interface{}", optionTemplateData, v)
+	}
+
+	shell := s.Option.string(optionShell, optionShellDefault)
+	if !strings.HasPrefix(shell, "/") || strings.ContainsAny(shell, " \t\r\n") {
+		return fmt.Errorf("invalid %s %q, want the absolute path of a shell", optionShell, shell)
+	}
+
+	argsFile := s.Option.string(optionArgsFile, "")
+	if argsFile != "" && (!filepath.IsAbs(argsFile) || strings.ContainsAny(argsFile, "\r\n")) {
+		return fmt.Errorf("%s %q must be an absolute path", optionArgsFile, argsFile)
+	}
+
+	logMaxSize := s.Option.int(optionLogMaxSize, 0)
+	if logMaxSize < 0 {
+		return fmt.Errorf("invalid %s %d, want 0 or more bytes", optionLogMaxSize, logMaxSize)
+	}
+
+	envFile := s.Option.string(optionEnvFile, "")
+	if envFile != "" && !filepath.IsAbs(envFile) {
+		return fmt.Errorf("%s %q must be an absolute path", optionEnvFile, envFile)
+	}
+
+	startBefore := s.Option.string(optionSysVStartBefore, "")
+	stopAfter := s.Option.string(optionSysVStopAfter, "")
+	if strings.ContainsAny(startBefore+stopAfter, "\r\n") {
+		return fmt.Errorf("%s and %s must not contain line breaks", optionSysVStartBefore, optionSysVStopAfter)
+	}
 
 	var to = &struct {
 		*Config
-		Path         string
-		LogDirectory string
+		Path                string
+		PIDFile             string
+		LogDirectory        string
+		StartBefore         string
+		StopAfter           string
+		ReloadSignal        string
+		ReloadCommand       string
+		AfterNetworkOnline  bool
+		ForceKill           bool
+		Restart             string
+		RestartSec          string
+		UMask               string
+		ExpandArgEnv        bool
+		ConditionPathExists string
+		EnvFile             string
+		StartRunlevels      []string
+		StopRunlevels       []string
+		StartPriority       string
+		KillPriority        string
+		CombinedOutput      bool
+		Extra               map[string]interface{}
+		Forking             bool
+		Shell               string
+		Nice                string
+		IONiceClass         string
+		IONicePriority      string
+		ArgsFile            string
+		LogMaxSize          int
+		Enabled             bool
+		ExePath             string
 	}{
 		s.Config,
 		path,
-		s.Option.string(optionLogDirectory, defaultLogDirectory),
+		s.pidFile(),
+		logDir,
+		startBefore,
+		stopAfter,
+		s.Option.string(optionReloadSignal, ""),
+		s.Option.string(optionReloadCommand, ""),
+		s.Option.bool(optionAfterNetworkOnline, optionAfterNetworkOnlineDefault),
+		s.Option.bool(optionForceKill, optionForceKillDefault),
+		policy,
+		strconv.FormatFloat(s.Option.duration(optionRestartSec, optionRestartSecDefault).Seconds(), 'f', -1, 64),
+		umask,
+		s.Option.bool(optionExpandArgEnv, optionExpandArgEnvDefault),
+		s.Option.string(optionConditionPathExists, ""),
+		envFile,
+		startLevels,
+		stopLevels,
+		startPriority,
+		killPriority,
+		s.Option.bool(optionCombinedOutput, optionCombinedOutputDefault),
+		s.templateData(),
+		forking,
+		shell,
+		nice,
+		ioniceClass,
+		ionicePriority,
+		argsFile,
+		logMaxSize,
+		s.Option.bool(optionEnabled, optionEnabledDefault),
+		filepath.Join(s.ChRoot, path),
 	}
 
-	err = s.template().Execute(f, to)
+	t, err := s.template()
 	if err != nil {
 		return err
 	}
+	return t.Execute(w, to)
+}
 
-	if err = os.Chmod(confPath, 0755); err != nil {
-		return err
+// sysvTemplate returns SysvTemplate, nil if it is not a *template.Template.
+func (s *sysv) sysvTemplate() *template.Template {
+	t, _ := s.Option[optionSysvTemplate].(*template.Template)
+	return t
+}
+
+// templateData returns TemplateData, nil if it is not a map[string]interface{}.
+func (s *sysv) templateData() map[string]interface{} {
+	extra, _ := s.Option[optionTemplateData].(map[string]interface{})
+	return extra
+}
+
+// Properties returns the variables the installed init script sets, with one
+// level of quotes removed.
+func (s *sysv) Properties() (map[string]string, error) {
+	confPath, err := s.configPath()
+	if err != nil {
+		return nil, err
 	}
-	for _, i := range [...]string{"2", "3", "4", "5"} {
-		if err = os.Symlink(confPath, "/etc/rc"+i+".d/S50"+s.Name); err != nil {
+	b, err := ioutil.ReadFile(s.staged(confPath))
+	if os.IsNotExist(err) {
+		return nil, ErrNotInstalled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseSysvProperties(string(b)), nil
+}
+
+var sysvAssignmentRe = regexp.MustCompile(`^(?:export )?([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+
+// parseSysvProperties parses the top level variable assignments of an init script.
+func parseSysvProperties(script string) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(script, "\n") {
+		matches := sysvAssignmentRe.FindStringSubmatch(line)
+		if matches == nil {
 			continue
 		}
+		v := matches[2]
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		props[matches[1]] = v
 	}
-	for _, i := range [...]string{"0", "1", "6"} {
-		if err = os.Symlink(confPath, "/etc/rc"+i+".d/K02"+s.Name); err != nil {
-			continue
+	return props
+}
+
+// Logs returns the last lines of the standard output log of the init script
+// followed by the last lines of its standard error log, unless CombinedOutput
+// writes both to the one log.
+func (s *sysv) Logs(lines int) ([]byte, error) {
+	logDir, err := s.logDirectory()
+	if err != nil {
+		return nil, err
+	}
+	if s.Option.bool(optionCombinedOutput, optionCombinedOutputDefault) {
+		return tailLogs(lines, filepath.Join(logDir, s.Name+".log"))
+	}
+	return tailLogs(lines, filepath.Join(logDir, s.Name+".log"), filepath.Join(logDir, s.Name+".err"))
+}
+
+// runlevels returns the StartRunlevels and StopRunlevels the service is
+// started and stopped in. Both the chkconfig and the LSB header are written
+// from these, as are the runlevel symlinks.
+func (s *sysv) runlevels() (start, stop []string, err error) {
+	start, err = sysvRunlevels(s.Option, optionStartRunlevels, optionStartRunlevelsDefault)
+	if err != nil {
+		return nil, nil, err
+	}
+	stop, err = sysvRunlevels(s.Option, optionStopRunlevels, optionStopRunlevelsDefault)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, l := range start {
+		for _, k := range stop {
+			if l == k {
+				return nil, nil, fmt.Errorf("runlevel %s is in both %s and %s", l, optionStartRunlevels, optionStopRunlevels)
+			}
+		}
+	}
+	return start, stop, nil
+}
+
+// sysvRunlevels parses the comma separated runlevels of option name.
+func sysvRunlevels(kv KeyValue, name, defaultValue string) ([]string, error) {
+	var levels []string
+	seen := map[string]bool{}
+	for _, l := range strings.Split(kv.string(name, defaultValue), ",") {
+		l = strings.TrimSpace(l)
+		if len(l) != 1 || l[0] < '0' || l[0] > '6' || seen[l] {
+			return nil, fmt.Errorf("invalid %s %q, want distinct runlevels from 0 to 6 such as %q", name, kv.string(name, defaultValue), defaultValue)
 		}
+		seen[l] = true
+		levels = append(levels, l)
 	}
+	return levels, nil
+}
 
+// sysvPriority returns the sequence number of the runlevel symlinks set by
+// option name as two digits.
+func sysvPriority(kv KeyValue, name string, defaultValue int) (string, error) {
+	p := kv.int(name, defaultValue)
+	if p < 0 || p > 99 {
+		return "", fmt.Errorf("invalid %s %d, want 0 to 99", name, p)
+	}
+	return fmt.Sprintf("%02d", p), nil
+}
+
+// nice returns the nice and ionice arguments of the init script, each empty
+// if its option is not set.
+func (s *sysv) nice() (nice, class, priority string, err error) {
+	if _, found := s.Option[optionNice]; found {
+		n := s.Option.int(optionNice, 0)
+		if n < -20 || n > 19 {
+			return "", "", "", fmt.Errorf("invalid %s %d, want -20 to 19", optionNice, n)
+		}
+		nice = strconv.Itoa(n)
+	}
+	if _, found := s.Option[optionIONiceClass]; found {
+		c := s.Option.int(optionIONiceClass, 0)
+		if c < 1 || c > 3 {
+			return "", "", "", fmt.Errorf("invalid %s %d, want 1, 2 or 3", optionIONiceClass, c)
+		}
+		class = strconv.Itoa(c)
+	}
+	if _, found := s.Option[optionIONicePriority]; found {
+		if class != "1" && class != "2" {
+			return "", "", "", fmt.Errorf("%s needs %s 1 or 2", optionIONicePriority, optionIONiceClass)
+		}
+		p := s.Option.int(optionIONicePriority, 0)
+		if p < 0 || p > 7 {
+			return "", "", "", fmt.Errorf("invalid %s %d, want 0 to 7", optionIONicePriority, p)
+		}
+		priority = strconv.Itoa(p)
+	}
+	return nice, class, priority, nil
+}
+
+// rcLinks returns the runlevel symlinks that start and stop the service
+// installed at confPath, in the rc<N>.d directories next to its InitDir.
+func (s *sysv) rcLinks(confPath string) ([]string, error) {
+	start, err := sysvPriority(s.Option, optionSysVStartPriority, optionSysVStartPriorityDefault)
+	if err != nil {
+		return nil, err
+	}
+	kill, err := sysvPriority(s.Option, optionSysVKillPriority, optionSysVKillPriorityDefault)
+	if err != nil {
+		return nil, err
+	}
+	startLevels, stopLevels, err := s.runlevels()
+	if err != nil {
+		return nil, err
+	}
+	rcDir := filepath.Dir(filepath.Dir(confPath))
+	var links []string
+	for _, i := range startLevels {
+		links = append(links, filepath.Join(rcDir, "rc"+i+".d", "S"+start+s.Name))
+	}
+	for _, i := range stopLevels {
+		links = append(links, filepath.Join(rcDir, "rc"+i+".d", "K"+kill+s.Name))
+	}
+	return links, nil
+}
+
+// Enable creates the runlevel symlinks so the installed service starts at boot.
+// Runlevel directories missing on this system are skipped.
+func (s *sysv) Enable() error {
+	confPath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(s.staged(confPath)); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+	links, err := s.rcLinks(confPath)
+	if err != nil {
+		return err
+	}
+	// A missing runlevel directory is skipped, as long as the service starts
+	// in at least one runlevel.
+	var failed, missing []string
+	started := false
+	for _, link := range links {
+		link = s.staged(link)
+		if _, err := os.Stat(filepath.Dir(link)); err != nil {
+			missing = append(missing, filepath.Dir(link))
+			continue
+		}
+		if err := os.Symlink(confPath, link); err != nil && !os.IsExist(err) {
+			failed = append(failed, err.Error())
+			continue
+		}
+		if strings.HasPrefix(filepath.Base(link), "S") {
+			started = true
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not create %d of %d runlevel symlinks: %s", len(failed), len(links), strings.Join(failed, "; "))
+	}
+	if !started {
+		return fmt.Errorf("no start runlevel symlink created, missing %s", strings.Join(missing, ", "))
+	}
 	return nil
 }
 
-func (s *sysv) Uninstall() error {
-	cp, err := s.configPath()
+// Disable removes the runlevel symlinks, the init script stays installed.
+func (s *sysv) Disable() error {
+	confPath, err := s.configPath()
 	if err != nil {
 		return err
 	}
-	if err := os.Remove(cp); err != nil {
+	links, err := s.rcLinks(confPath)
+	if err != nil {
 		return err
 	}
+	for _, link := range links {
+		if err := os.Remove(s.staged(link)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
 	return nil
 }
 
+// Installed reports whether the init script exists. Unlike Status it does not
+// run the service command.
+func (s *sysv) Installed() (bool, error) {
+	confPath, err := s.configPath()
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(s.staged(confPath))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// monitTemplate renders the monit fragment of MonitIntegration.
+var monitTemplate = template.Must(template.New("").Parse(sysvMarker + `
+check process {{.Name}} with pidfile "{{.PIDFile}}"
+    start program = "{{.Script}} start"
+    stop program = "{{.Script}} stop"
+`))
+
+// sysvMarker is embedded in every init script rendered from sysvScript so
+// InstalledServices can tell them from scripts installed by other means.
+const sysvMarker = "# Managed-By: github.com/kardianos/service"
+
+// installedServices returns the names of the regular files in dir that
+// contain sysvMarker, sorted by name.
+func installedServices(dir string) ([]string, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fi := range fis {
+		if !fi.Mode().IsRegular() || validateName(fi.Name()) != nil {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			if strings.TrimSpace(line) == sysvMarker {
+				names = append(names, fi.Name())
+				break
+			}
+		}
+	}
+	return names, nil
+}
+
+func (s *sysv) Uninstall() (err error) {
+	defer observe("uninstall", time.Now(), &err)
+	cp, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	staging := s.Option.string(optionRootPrefix, "") != ""
+	cp = s.staged(cp)
+	if !staging && s.Option.bool(optionStopBeforeUninstall, optionStopBeforeUninstallDefault) {
+		if status, err := s.Status(); err == nil && (status == StatusRunning || status == StatusDegraded) {
+			if err := s.stopForUninstall(); err != nil {
+				return err
+			}
+		}
+	}
+	if argsFile := s.Option.string(optionArgsFile, ""); argsFile != "" {
+		if err := os.Remove(s.staged(argsFile)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if s.Option.bool(optionMonitIntegration, false) {
+		if err := os.Remove(s.staged(s.monitPath())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if s.Option.bool(optionRestoreOnUninstall, optionRestoreOnUninstallDefault) {
+		backups, err := filepath.Glob(cp + ".bak-*")
+		if err != nil {
+			return err
+		}
+		if len(backups) > 0 {
+			// The timestamp sorts, Glob returns the most recent backup last.
+			return os.Rename(backups[len(backups)-1], cp)
+		}
+	}
+	return os.Remove(cp)
+}
+
 func (s *sysv) Logger(errs chan<- error) (Logger, error) {
 	if system.Interactive() {
 		return ConsoleLogger, nil
@@ -133,27 +1058,92 @@ func (s *sysv) Logger(errs chan<- error) (Logger, error) {
 	return s.SystemLogger(errs)
 }
 func (s *sysv) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	switch backend := s.Option.string(optionSystemLoggerBackend, systemLoggerSyslog); backend {
+	case systemLoggerSyslog:
+		return s.loggers.add(newSysLogger(s.Option, s.Name, errs))
+	case systemLoggerFile:
+		// Same file the init script redirects standard output to.
+		logDir, err := s.logDirectory()
+		if err != nil {
+			return nil, err
+		}
+		return s.loggers.add(newFileLogger(filepath.Join(logDir, s.Name+".log"), errs))
+	default:
+		return nil, fmt.Errorf("invalid %s %q, want %q or %q", optionSystemLoggerBackend, backend, systemLoggerSyslog, systemLoggerFile)
+	}
 }
 
 func (s *sysv) Run() (err error) {
-	err = s.i.Start(s)
+	if name := s.Option.string(optionProcessName, ""); name != "" {
+		if err = setProcessName(name); err != nil {
+			return err
+		}
+	}
+
+	if s.Option.bool(optionSingleInstance, optionSingleInstanceDefault) {
+		f, err := lockPIDFile(s.pidFile())
+		if err != nil {
+			return err
+		}
+		defer unlockPIDFile(f)
+	}
+
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	defer s.loggers.flush()
+
+	err = callInterface(s, s.Option, s.i.Start)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		waitForStopSignal(s, s.i)
 	})()
 
-	return s.i.Stop(s)
+	return callInterface(s, s.Option, s.i.Stop)
 }
 
 func (s *sysv) Status() (Status, error) {
-	_, out, err := runWithOutput("service", s.Name, "status")
-	if err != nil {
+	ctx := context.Background()
+	if timeout := s.Option.duration(optionStatusTimeout, optionStatusTimeoutDefault); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return s.StatusContext(ctx)
+}
+
+// StatusContext is Status, canceling the service command when ctx is done.
+func (s *sysv) StatusContext(ctx context.Context) (status Status, err error) {
+	defer observe("status", time.Now(), &err)
+	status, err = s.status(ctx)
+	return checkHealth(ctx, s.Option, status, err)
+}
+
+// CombinedStatus reports State and, while running, the PID from PIDFile. Under
+// RestartPolicy that is the pid of the service, not of its supervisor.
+func (s *sysv) CombinedStatus() ServiceStatus {
+	state, err := s.Status()
+	st := ServiceStatus{State: state, LastError: err}
+	if state == StatusRunning || state == StatusDegraded {
+		path := s.pidFile()
+		if s.supervised() {
+			path += ".child"
+		}
+		if b, err := ioutil.ReadFile(path); err == nil {
+			st.PID, _ = strconv.Atoi(strings.TrimSpace(string(b)))
+		}
+	}
+	return st
+}
+
+func (s *sysv) status(ctx context.Context) (Status, error) {
+	exitCode, out, err := s.runServiceCommand(ctx, "status", true)
+	// The init script exits 1 when the service is stopped.
+	if exitCode == 0 && err != nil {
 		return StatusUnknown, err
 	}
 
@@ -168,57 +1158,320 @@ func (s *sysv) Status() (Status, error) {
 }
 
 func (s *sysv) Start() error {
-	return run("service", s.Name, "start")
+	return s.StartContext(context.Background())
+}
+
+// StartContext is Start, canceling the service command when ctx is done.
+// A failed start command is retried StartRetries times.
+func (s *sysv) StartContext(ctx context.Context) (err error) {
+	defer observe("start", time.Now(), &err)
+	if _, _, err := s.serviceCommand("start"); err != nil {
+		return err
+	}
+	retries := s.Option.int(optionStartRetries, optionStartRetriesDefault)
+	delay := s.Option.duration(optionStartRetryDelay, optionStartRetryDelayDefault)
+	for attempt := 0; ; attempt++ {
+		_, _, err := s.runServiceCommand(ctx, "start", false)
+		err = s.checkInstalled(err)
+		if err == nil || err == ErrNotInstalled || attempt >= retries || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-time.After(delay << uint(attempt)):
+		case <-ctx.Done():
+			return err
+		}
+	}
 }
 
 func (s *sysv) Stop() error {
-	return run("service", s.Name, "stop")
+	return s.StopContext(context.Background())
 }
 
-func (s *sysv) Restart() error {
-	err := s.Stop()
+// StopContext is Stop, canceling the service command when ctx is done. A stop
+// that fails succeeds anyway if the service is stopped.
+func (s *sysv) StopContext(ctx context.Context) (err error) {
+	defer observe("stop", time.Now(), &err)
+	if _, _, err := s.serviceCommand("stop"); err != nil {
+		return err
+	}
+	_, _, err = s.runServiceCommand(ctx, "stop", false)
+	if cmdErr, ok := err.(*CommandError); ok && cmdErr.ExitCode > 0 {
+		// Some init scripts exit non-zero if the service is not running.
+		if status, serr := s.status(ctx); serr == nil && status == StatusStopped {
+			return nil
+		}
+	}
+	return s.checkInstalled(err)
+}
+
+// checkInstalled returns ErrNotInstalled if a service command failed because
+// the init script does not exist, and err otherwise.
+func (s *sysv) checkInstalled(err error) error {
+	if err == nil {
+		return nil
+	}
+	if installed, ierr := s.Installed(); ierr == nil && !installed {
+		return ErrNotInstalled
+	}
+	return err
+}
+
+func (s *sysv) DisableAndStop() error {
+	if err := s.Disable(); err != nil {
+		return err
+	}
+	return s.Stop()
+}
+
+func (s *sysv) Restart() (err error) {
+	defer observe("restart", time.Now(), &err)
+	delay, err := restartDelay(s.Option)
 	if err != nil {
 		return err
 	}
-	time.Sleep(50 * time.Millisecond)
+	err = s.Stop()
+	if err != nil {
+		return err
+	}
+	if err = s.waitForStop(s.Option.duration(optionStopTimeout, optionStopTimeoutDefault)); err != nil {
+		return err
+	}
+	time.Sleep(delay)
 	return s.Start()
 }
 
-const sysvScript = `#!/bin/sh
+// TryRestart restarts the service like Restart if it is running and does
+// nothing otherwise, as the condrestart and try-restart actions of the init
+// script do.
+func (s *sysv) TryRestart() error {
+	status, err := s.Status()
+	if err != nil {
+		return err
+	}
+	if status != StatusRunning && status != StatusDegraded {
+		return nil
+	}
+	return s.Restart()
+}
+
+// uninstallStopTimeout is how long Uninstall waits for the service to stop if
+// StopTimeout is zero, and for it to exit after it was killed.
+const uninstallStopTimeout = 5 * time.Second
+
+// stopForUninstall stops the service and waits for it to exit. A service that
+// does not stop is killed, it could not be stopped once its init script is gone.
+func (s *sysv) stopForUninstall() error {
+	timeout := s.Option.duration(optionStopTimeout, optionStopTimeoutDefault)
+	if timeout <= 0 {
+		timeout = uninstallStopTimeout
+	}
+	err := s.Stop()
+	if err == nil {
+		err = s.waitForStop(timeout)
+	}
+	if err == nil {
+		return nil
+	}
+	if kerr := s.kill(); kerr != nil {
+		return fmt.Errorf("%v, and could not kill it: %v", err, kerr)
+	}
+	return s.waitForStop(uninstallStopTimeout)
+}
+
+// kill sends SIGKILL to the pid in PIDFile and, under RestartPolicy, then to
+// the supervised process whose pid is next to it. A pid that does not belong
+// to the service is skipped.
+func (s *sysv) kill() error {
+	for i, path := range []string{s.pidFile(), s.pidFile() + ".child"} {
+		b, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) && i > 0 {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil || pid <= 1 {
+			return fmt.Errorf("invalid pid %q in %s", strings.TrimSpace(string(b)), path)
+		}
+		if !s.ownsPid(pid, i == 0 && s.supervised()) {
+			continue
+		}
+		if err := syscall.Kill(pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+			return err
+		}
+	}
+	return nil
+}
+
+// supervised reports whether the init script runs the service under the
+// supervisor of RestartPolicy, which keeps its own pid in PIDFile.
+func (s *sysv) supervised() bool {
+	policy, err := restartPolicy(s.Option)
+	return err == nil && policy != "" && policy != restartPolicyNo
+}
+
+// ownsPid reports whether pid runs the executable of the service or, for the
+// supervisor of RestartPolicy, the init script. A pid file left behind by a
+// service that died may name a process that reused the pid.
+func (s *sysv) ownsPid(pid int, supervisor bool) bool {
+	b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return false
+	}
+	var want string
+	if supervisor {
+		want, err = s.configPath()
+	} else {
+		want, err = s.execPath()
+	}
+	if err != nil {
+		return false
+	}
+	if !supervisor {
+		exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+		if err == nil && exe == filepath.Join(s.ChRoot, want) {
+			return true
+		}
+	}
+	// A script runs with its interpreter as argv[0] and its path as argv[1].
+	// Kernel threads and zombies have an empty cmdline.
+	args := strings.Split(string(b), "\x00")
+	for i := 0; i < len(args) && i < 2; i++ {
+		if args[i] == want {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForStop polls Status until the service is stopped or timeout elapses.
+// A zero timeout returns at once.
+func (s *sysv) waitForStop(timeout time.Duration) error {
+	if timeout <= 0 {
+		return nil
+	}
+	var err error
+	stopped := waitUntil(timeout, 100*time.Millisecond, func() bool {
+		var status Status
+		status, err = s.Status()
+		return err != nil || status == StatusStopped
+	})
+	if err != nil || stopped {
+		return err
+	}
+	b, _ := ioutil.ReadFile(s.pidFile())
+	return fmt.Errorf("%s still running with pid %s after %v", s.Name, strings.TrimSpace(string(b)), timeout)
+}
+
+const sysvScript = `#!{{.Shell}}
+` + sysvMarker + `
 # For RedHat and cousins:
-# chkconfig: - 99 01
+# chkconfig: {{if .Enabled}}{{range .StartRunlevels}}{{.}}{{end}}{{else}}-{{end}} {{.StartPriority}} {{.KillPriority}}
 # description: {{.Description}}
 # processname: {{.Path}}
 
 ### BEGIN INIT INFO
 # Provides:          {{.Path}}
-# Required-Start:
-# Required-Stop:
-# Default-Start:     2 3 4 5
-# Default-Stop:      0 1 6
-# Short-Description: {{.DisplayName}}
+# Required-Start:{{if .AfterNetworkOnline}}    $network $remote_fs{{end}}
+# Required-Stop:{{if .AfterNetworkOnline}}     $network $remote_fs{{end}}
+# Default-Start:     {{join .StartRunlevels " "}}
+# Default-Stop:      {{join .StopRunlevels " "}}
+{{if .StartBefore}}# X-Start-Before:    {{.StartBefore}}
+{{end}}{{if .StopAfter}}# X-Stop-After:      {{.StopAfter}}
+{{end}}# Short-Description: {{.DisplayName}}
 # Description:       {{.Description}}
 ### END INIT INFO
 
-cmd="{{.Path}}{{range .Arguments}} {{.|cmd}}{{end}}"
-
-name=$(basename $(readlink -f $0))
-pid_file="/var/run/$name.pid"
+start_cmd() {
+{{- if .ArgsFile}}
+    args=$(cat {{.ArgsFile|cmd}}) || exit 1
+    eval "set -- $args"
+{{- end}}
+{{- if .IONiceClass}}
+    ionice=
+    if command -v ionice > /dev/null 2>&1; then
+        ionice="ionice -c {{.IONiceClass}}{{if .IONicePriority}} -n {{.IONicePriority}}{{end}}"
+    fi
+    # $ionice is split into its arguments, or is no word at all if empty.
+    # shellcheck disable=SC2086
+{{- end}}
+    exec {{if .Nice}}nice -n {{.Nice}} {{end}}{{if .IONiceClass}}$ionice {{end}}{{if .ChRoot}}chroot {{.ChRoot|cmd}} {{end}}{{.Path|cmd}}{{if .ArgsFile}} "$@"{{else}}{{range .Arguments}} {{if $.ExpandArgEnv}}{{.|cmdExpand}}{{else}}{{.|cmd}}{{end}}{{end}}{{end}}
+}
+{{if .Restart}}
+# supervise restarts start_cmd when it exits{{if eq .Restart "on-failure"}} with a non-zero status{{end}}.
+# Its pid is in $pid_file, the pid of the service in $child_pid_file.
+supervise() {
+    trap 'kill "$child" 2> /dev/null; wait "$child"; rm -f "$child_pid_file"; exit 0' TERM
+    while :
+    do
+        start_cmd &
+        child=$!
+        echo "$child" > "$child_pid_file"
+        wait "$child"
+{{- if eq .Restart "on-failure"}}
+        if [ $? -eq 0 ]; then
+            break
+        fi
+{{- end}}
+        sleep {{.RestartSec}} &
+        wait "$!"
+    done
+    rm -f "$child_pid_file"
+}
+{{end}}
+name=$(basename "$(readlink -f "$0")")
+pid_file={{.PIDFile|cmd}}
+{{- if .Restart}}
+child_pid_file="$pid_file.child"
+{{- end}}
 stdout_log="{{.LogDirectory}}/$name.log"
+{{- if not .CombinedOutput}}
 stderr_log="{{.LogDirectory}}/$name.err"
+{{- end}}
 
 {{range $k, $v := .EnvVars -}}
 export {{$k}}={{$v}}
 {{end -}}
 
-[ -e /etc/sysconfig/$name ] && . /etc/sysconfig/$name
-
+{{if .EnvFile -}}
+[ -e {{.EnvFile|cmd}} ] && . {{.EnvFile|cmd}}
+{{else -}}
+[ -e "/etc/sysconfig/$name" ] && . "/etc/sysconfig/$name"
+[ -e "/etc/default/$name" ] && . "/etc/default/$name"
+{{end}}
 get_pid() {
     cat "$pid_file"
 }
 
+pid_alive() {
+    [ -f "$pid_file" ] && cat "/proc/$(get_pid)/stat" > /dev/null 2>&1
+}
+
+# is_service reports whether process $1 runs the service and not another
+# process that was given a recycled pid.
+is_service() {
+{{- if .Restart}}
+    # The supervisor is a copy of this script, run by the shell with the
+    # script, or an rc link to it, as argv[1].
+    arg=$(tr '\0' '\n' < "/proc/$1/cmdline" 2> /dev/null | sed -n 2p)
+    case "$arg" in
+        "") return 1 ;;
+        /*) ;;
+        *) arg="/proc/$1/cwd/$arg" ;;
+    esac
+    [ "$(readlink -f "$arg")" = "$(readlink -f "$0")" ]
+{{- else}}
+    # The executable stays the same when the service rewrites its argv.
+    [ "$(readlink "/proc/$1/exe" 2> /dev/null)" = "$(readlink -f {{.ExePath|cmd}})" ] && return 0
+    # A script runs with its interpreter as argv[0] and its path as argv[1].
+    tr '\0' '\n' < "/proc/$1/cmdline" 2> /dev/null | head -n 2 | grep -qxF -- {{.Path|cmd}}
+{{- end}}
+}
+
 is_running() {
-    [ -f "$pid_file" ] && cat /proc/$(get_pid)/stat > /dev/null 2>&1
+    pid_alive && is_service "$(get_pid)"
 }
 
 case "$1" in
@@ -226,12 +1479,56 @@ case "$1" in
         if is_running; then
             echo "Already started"
         else
+            if [ -f "$pid_file" ]; then
+                echo "Removing stale pid file $pid_file"
+                rm -f "$pid_file"{{if .Restart}} "$child_pid_file"{{end}}
+            fi
+{{- if .ConditionPathExists}}
+            if [ ! -e {{.ConditionPathExists|cmd}} ]; then
+                echo "Not starting $name, condition not met: "{{.ConditionPathExists|cmd}}" does not exist"
+                exit 0
+            fi
+{{- end}}
+{{- if .LogMaxSize}}
+            for log in "$stdout_log"{{if not .CombinedOutput}} "$stderr_log"{{end}}
+            do
+                if [ -f "$log" ] && [ "$(wc -c < "$log")" -gt {{.LogMaxSize}} ]; then
+                    mv -f "$log" "$log.1"
+                    echo "--- $(date '+%Y-%m-%d %H:%M:%S') rotated to $log.1 ---" > "$log"
+                fi
+            done
+{{- end}}
             echo "Starting $name"
-            {{if .WorkingDirectory}}cd '{{.WorkingDirectory}}'{{end}}
-            $cmd >> "$stdout_log" 2>> "$stderr_log" &
-            echo $! > "$pid_file"
-            if ! is_running; then
-                echo "Unable to start, see $stdout_log and $stderr_log"
+            {{if .WorkingDirectory}}cd {{.WorkingDirectory|cmd}}{{end}}
+{{- if .UMask}}
+            umask {{.UMask}}
+{{- end}}
+{{- if .Forking}}
+            # start_cmd daemonizes, the daemon writes $pid_file itself.
+            rm -f "$pid_file"
+            (start_cmd) >> "$stdout_log" {{if .CombinedOutput}}2>&1{{else}}2>> "$stderr_log"{{end}}
+            for i in $(seq 1 10)
+            do
+                if is_running; then
+                    break
+                fi
+                sleep 1
+            done
+{{- else}}
+{{- if .Restart}}
+            # The subshell keeps the shell from handing its saved stdout and
+            # stderr to supervise, which never runs exec to close them.
+            (supervise) >> "$stdout_log" {{if .CombinedOutput}}2>&1{{else}}2>> "$stderr_log"{{end}} &
+{{- else}}
+            start_cmd >> "$stdout_log" {{if .CombinedOutput}}2>&1{{else}}2>> "$stderr_log"{{end}} &
+{{- end}}
+            echo "$!" > "$pid_file"
+{{- end}}
+{{- if not .Forking}}
+            # start_cmd may not have run exec yet, so only the pid is checked.
+{{- end}}
+            if ! {{if .Forking}}is_running{{else}}pid_alive{{end}}; then
+                echo "Unable to start, see $stdout_log{{if not .CombinedOutput}} and $stderr_log{{end}}"
                 exit 1
             fi
         fi
@@ -239,7 +1536,7 @@ case "$1" in
     stop)
         if is_running; then
             echo -n "Stopping $name.."
-            kill $(get_pid)
+            kill "$(get_pid)"
             for i in $(seq 1 10)
             do
                 if ! is_running; then
@@ -249,6 +1546,17 @@ case "$1" in
                 sleep 1
             done
             echo
+{{- if .ForceKill}}
+            if is_running; then
+                echo "Not stopped after grace period; killing $name"
+                kill -9 "$(get_pid)"
+{{- if .Restart}}
+                [ -f "$child_pid_file" ] && kill -9 "$(cat "$child_pid_file")" 2> /dev/null
+                rm -f "$child_pid_file"
+{{- end}}
+                sleep 1
+            fi
+{{- end}}
             if is_running; then
                 echo "Not stopped; may still be shutting down or shutdown may have failed"
                 exit 1
@@ -263,12 +1571,17 @@ case "$1" in
         fi
     ;;
     restart)
-        $0 stop
+        "$0" stop
         if is_running; then
             echo "Unable to stop, will not attempt to start"
             exit 1
         fi
-        $0 start
+        "$0" start
+    ;;
+    condrestart|try-restart)
+        if is_running; then
+            "$0" restart || exit 1
+        fi
     ;;
     status)
         if is_running; then
@@ -278,8 +1591,25 @@ case "$1" in
             exit 1
         fi
     ;;
+{{- if or .ReloadCommand .ReloadSignal}}
+    reload)
+        if is_running; then
+{{- if .ReloadCommand}}
+            {{.ReloadCommand}}
+{{- else if .Restart}}
+            # $pid_file holds the supervisor, which would die of the signal.
+            kill -{{.ReloadSignal}} "$(cat "$child_pid_file")"
+{{- else}}
+            kill -{{.ReloadSignal}} "$(get_pid)"
+{{- end}}
+        else
+            echo "Not running"
+            exit 1
+        fi
+    ;;
+{{- end}}
     *)
-    echo "Usage: $0 {start|stop|restart|status}"
+    echo "Usage: $0 {start|stop|restart|condrestart|try-restart|status{{if or .ReloadCommand .ReloadSignal}}|reload{{end}}}"
     exit 1
     ;;
 esac
@@ -0,0 +1,2669 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// fakeServiceCommand puts a "service" script first in PATH that records each
+// action and the time it ran in the returned log file.
+func fakeServiceCommand(t *testing.T) (logPath string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logPath = filepath.Join(dir, "calls")
+	script := "#!/bin/sh\necho \"$2 $(date +%s%N)\" >> " + logPath + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "service"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+	return logPath, func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSysvRestartDelay(t *testing.T) {
+	logPath, cleanup := fakeServiceCommand(t)
+	defer cleanup()
+
+	const delay = 300 * time.Millisecond
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionRestartDelay: delay}}}
+	if err := s.Restart(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := strings.Fields(string(b))
+	if len(calls) != 4 || calls[0] != "stop" || calls[2] != "start" {
+		t.Fatalf("service calls = %q, want stop then start", calls)
+	}
+	stopped, _ := strconv.ParseInt(calls[1], 10, 64)
+	started, _ := strconv.ParseInt(calls[3], 10, 64)
+	if gap := time.Duration(started - stopped); gap < delay {
+		t.Errorf("Restart waited %v between stop and start, want at least %v", gap, delay)
+	}
+}
+
+func TestSysvRestartDelayNegative(t *testing.T) {
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionRestartDelay: "-1s"}}}
+	if err := s.Restart(); err == nil {
+		t.Error("Restart() with negative RestartDelay succeeded, want error")
+	}
+}
+
+func TestSysvSingleInstance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "test.pid")
+
+	f, err := lockPIDFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.TrimSpace(string(b)), strconv.Itoa(os.Getpid()); got != want {
+		t.Errorf("pid file contains %q, want %q", got, want)
+	}
+
+	s := &sysv{Config: &Config{
+		Name: "test",
+		Option: KeyValue{
+			optionSingleInstance: true,
+			optionPIDFile:        pidFile,
+		},
+	}}
+	if err := s.Run(); err != ErrAlreadyRunning {
+		t.Errorf("Run() error = %v, want %v", err, ErrAlreadyRunning)
+	}
+
+	if err := unlockPIDFile(f); err != nil {
+		t.Fatal(err)
+	}
+	f, err = lockPIDFile(pidFile)
+	if err != nil {
+		t.Fatalf("lockPIDFile() after unlock error = %v", err)
+	}
+	unlockPIDFile(f)
+}
+
+func TestSysvEffectiveConfig(t *testing.T) {
+	s := &sysv{Config: &Config{
+		Name: "test",
+		Option: KeyValue{
+			optionLogDirectory: "/srv/log",
+			optionRestartDelay: "2s",
+		},
+	}}
+	got := s.EffectiveConfig()
+	want := map[string]interface{}{
+		optionUserService:         false,
+		optionSysvScript:          "",
+		optionSysvTemplate:        (*template.Template)(nil),
+		optionTemplateData:        map[string]interface{}(nil),
+		optionPIDFile:             filepath.Join(pidDir(), "test.pid"),
+		optionLogDirectory:        "/srv/log",
+		optionProcessName:         "",
+		optionSingleInstance:      false,
+		optionEnabled:             true,
+		optionSysVStartBefore:     "",
+		optionSysVStopAfter:       "",
+		optionReloadSignal:        "",
+		optionReloadCommand:       "",
+		optionSystemLoggerBackend: systemLoggerSyslog,
+		optionSyslogFacility:      "",
+		optionSyslogTag:           "test",
+		optionAfterNetworkOnline:  false,
+		optionForceKill:           false,
+		optionRestartPolicy:       "",
+		optionRestartSec:          time.Second,
+		optionUMask:               "",
+		optionNice:                0,
+		optionIONiceClass:         0,
+		optionIONicePriority:      0,
+		optionInitDir:             optionInitDirDefault,
+		optionStatusTimeout:       5 * time.Second,
+		optionExpandArgEnv:        false,
+		optionConditionPathExists: "",
+		optionSkipExecCheck:       false,
+		optionBackupOnInstall:     false,
+		optionRestoreOnUninstall:  false,
+		optionStartAfterInstall:   false,
+		optionStopBeforeUninstall: false,
+		optionEnvFile:             "",
+		optionSysVStartPriority:   optionSysVStartPriorityDefault,
+		optionSysVKillPriority:    optionSysVKillPriorityDefault,
+		optionStartRunlevels:      optionStartRunlevelsDefault,
+		optionStopRunlevels:       optionStopRunlevelsDefault,
+		optionCombinedOutput:      false,
+		optionStartRetries:        optionStartRetriesDefault,
+		optionForking:             false,
+		optionRootPrefix:          "",
+		optionShell:               optionShellDefault,
+		optionPreferInitScript:    false,
+		optionServiceCommand:      optionServiceCommandDefault,
+		optionArgsFile:            "",
+		optionLogMaxSize:          0,
+		optionMonitIntegration:    false,
+		optionMonitDir:            "/etc/monit.d",
+		optionStopTimeout:         time.Duration(0),
+		optionStartRetryDelay:     optionStartRetryDelayDefault,
+		optionRestartDelay:        2 * time.Second,
+		optionReapChildren:        false,
+		optionHealthCheckCommand:  "",
+		optionFileMode:            os.FileMode(0755),
+	}
+	if len(got) != len(want) {
+		t.Errorf("EffectiveConfig() has %d options, want %d", len(got), len(want))
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(got[k], v) {
+			t.Errorf("EffectiveConfig()[%q] = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestSysvInstallInvalidName(t *testing.T) {
+	for _, name := range []string{"../etc/passwd", "my service", "a/b", "name;reboot", ""} {
+		s := &sysv{Config: &Config{Name: name}}
+		if err := s.Install(); err == nil || !strings.Contains(err.Error(), "invalid service name") {
+			t.Errorf("Install() with name %q error = %v, want invalid service name", name, err)
+		}
+	}
+	if err := validateName("my-service_2"); err != nil {
+		t.Errorf("validateName() error = %v, want nil", err)
+	}
+}
+
+func TestSysvEnableDisable(t *testing.T) {
+	s := &sysv{Config: &Config{Name: "servicetest-enable"}}
+	links, err := s.rcLinks("/etc/init.d/servicetest-enable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(links) != 7 {
+		t.Fatalf("rcLinks() returned %d links, want 7", len(links))
+	}
+	if links[0] != "/etc/rc2.d/S50servicetest-enable" || links[6] != "/etc/rc6.d/K02servicetest-enable" {
+		t.Errorf("rcLinks() = %q", links)
+	}
+	if err := s.Enable(); err != ErrNotInstalled {
+		t.Errorf("Enable() of a missing script error = %v, want %v", err, ErrNotInstalled)
+	}
+	if err := s.Disable(); err != nil {
+		t.Errorf("Disable() without links error = %v, want nil", err)
+	}
+}
+
+func TestSysvStartBeforeStopAfter(t *testing.T) {
+	s := &sysv{Config: &Config{
+		Name: "test",
+		Option: KeyValue{
+			optionSysVStartBefore: "app1 app2",
+			optionSysVStopAfter:   "app1",
+		},
+	}}
+	var b bytes.Buffer
+	if err := s.render(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\n# X-Start-Before:    app1 app2\n",
+		"\n# X-Stop-After:      app1\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("init script is missing %q:\n%s", want, b.String())
+		}
+	}
+
+	b.Reset()
+	s.Option = nil
+	if err := s.render(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "X-Start-Before") || strings.Contains(b.String(), "X-Stop-After") {
+		t.Errorf("init script has ordering headers without the options:\n%s", b.String())
+	}
+
+	s.Option = KeyValue{optionSysVStartBefore: "app1\nexit"}
+	if err := s.render(&b); err == nil {
+		t.Error("render() with a line break in SysVStartBefore succeeded, want error")
+	}
+}
+
+func TestSysvReloadCommand(t *testing.T) {
+	tests := []struct {
+		name   string
+		option KeyValue
+		want   string
+	}{
+		{"none", nil, ""},
+		{"signal", KeyValue{optionReloadSignal: "HUP"}, "kill -HUP \"$(get_pid)\""},
+		{"command", KeyValue{optionReloadCommand: "kill -USR2 $(get_pid)"}, "kill -USR2 $(get_pid)"},
+		{"command wins", KeyValue{optionReloadSignal: "HUP", optionReloadCommand: "/usr/bin/app reload"}, "/usr/bin/app reload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &sysv{Config: &Config{Name: "test", Option: tt.option}}
+			var b bytes.Buffer
+			if err := s.render(&b); err != nil {
+				t.Fatal(err)
+			}
+			script := b.String()
+			if tt.want == "" {
+				if strings.Contains(script, "reload)") {
+					t.Errorf("init script has a reload case:\n%s", script)
+				}
+				if Capabilities(s).Has(CapabilityReload) {
+					t.Error("Capabilities() has CapabilityReload")
+				}
+			} else {
+				if !strings.Contains(script, "reload)\n        if is_running; then\n            "+tt.want+"\n") {
+					t.Errorf("init script reload case does not run %q:\n%s", tt.want, script)
+				}
+				if strings.Contains(script, "kill -HUP") && tt.name == "command wins" {
+					t.Error("init script sends ReloadSignal although ReloadCommand is set")
+				}
+				if !Capabilities(s).Has(CapabilityReload) {
+					t.Error("Capabilities() is missing CapabilityReload")
+				}
+			}
+			if out, err := exec.Command("sh", "-n", "-c", script).CombinedOutput(); err != nil {
+				t.Errorf("init script is not valid shell: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+func TestSysvFileLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &sysv{Config: &Config{
+		Name: "test",
+		Option: KeyValue{
+			optionSystemLoggerBackend: systemLoggerFile,
+			optionLogDirectory:        dir,
+		},
+	}}
+	l, err := s.SystemLogger(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info("info")
+	l.Warningf("warning %d", 2)
+	l.Error("error")
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "test.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("log file has %d lines, want 3:\n%s", len(lines), b)
+	}
+	for i, want := range []string{"I: ", "W: ", "E: "} {
+		if !strings.HasPrefix(lines[i], want) {
+			t.Errorf("line %d = %q, want prefix %q", i, lines[i], want)
+		}
+	}
+	if !strings.HasSuffix(lines[1], "warning 2") {
+		t.Errorf("line 1 = %q, want formatted message", lines[1])
+	}
+
+	s.Option[optionSystemLoggerBackend] = "journal"
+	if _, err := s.SystemLogger(nil); err == nil {
+		t.Error("SystemLogger() with an invalid backend succeeded, want error")
+	}
+}
+
+func TestSysvStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  fakeResult
+		want    Status
+		wantErr error
+	}{
+		{"running", fakeResult{0, "Running\n"}, StatusRunning, nil},
+		{"stopped", fakeResult{1, "Stopped\n"}, StatusStopped, nil},
+		{"unknown", fakeResult{1, "test: unrecognized service\n"}, StatusUnknown, ErrNotInstalled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, restore := fakeCommandRunner(map[string]fakeResult{"service test status": tt.result})
+			defer restore()
+
+			s := &sysv{Config: &Config{Name: "test"}}
+			got, err := s.Status()
+			if got != tt.want || err != tt.wantErr {
+				t.Errorf("Status() = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+			if len(*calls) != 1 {
+				t.Errorf("Status() ran %q, want one command", *calls)
+			}
+		})
+	}
+}
+
+func TestSysvHealthCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		status fakeResult
+		check  fakeResult
+		want   Status
+		ran    int
+	}{
+		{"running", fakeResult{0, "Running\n"}, fakeResult{}, StatusRunning, 2},
+		{"degraded", fakeResult{0, "Running\n"}, fakeResult{1, ""}, StatusDegraded, 2},
+		{"stopped", fakeResult{1, "Stopped\n"}, fakeResult{1, ""}, StatusStopped, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, restore := fakeCommandRunner(map[string]fakeResult{
+				"service test status":  tt.status,
+				"/bin/sh -c check-app": tt.check,
+			})
+			defer restore()
+
+			s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionHealthCheckCommand: "check-app"}}}
+			got, err := s.Status()
+			if got != tt.want || err != nil {
+				t.Errorf("Status() = %v, %v, want %v, nil", got, err, tt.want)
+			}
+			if len(*calls) != tt.ran {
+				t.Errorf("Status() ran %q, want %d commands", *calls, tt.ran)
+			}
+		})
+	}
+}
+
+func TestSysvControl(t *testing.T) {
+	calls, restore := fakeCommandRunner(map[string]fakeResult{
+		"service test start": {},
+		"service test stop":  {},
+	})
+	defer restore()
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionRestartDelay: time.Duration(0)}}}
+	for _, f := range []func() error{s.Start, s.Stop, s.Restart} {
+		if err := f(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "service test start,service test stop,service test stop,service test start"
+	if got := strings.Join(*calls, ","); got != want {
+		t.Errorf("commands = %q, want %q", got, want)
+	}
+
+	*calls = nil
+	s.Name = "missing"
+	for _, f := range []func() error{s.Start, s.Stop, s.Restart} {
+		if err := f(); err != ErrNotInstalled {
+			t.Errorf("error without an init script = %v, want %v", err, ErrNotInstalled)
+		}
+	}
+}
+
+func TestSysvTryRestart(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		status fakeResult
+		want   string
+	}{
+		{"running", fakeResult{0, "Running\n"}, "service test status,service test stop,service test start"},
+		{"stopped", fakeResult{1, "Stopped\n"}, "service test status"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, restore := fakeCommandRunner(map[string]fakeResult{
+				"service test status": tt.status,
+				"service test start":  {},
+				"service test stop":   {},
+			})
+			defer restore()
+
+			s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionRestartDelay: time.Duration(0)}}}
+			if err := s.TryRestart(); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(*calls, ","); got != tt.want {
+				t.Errorf("TryRestart() ran %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, restore := fakeCommandRunner(nil)
+	defer restore()
+	s := &sysv{Config: &Config{Name: "missing"}}
+	if err := s.TryRestart(); err != ErrNotInstalled {
+		t.Errorf("TryRestart() without an init script = %v, want %v", err, ErrNotInstalled)
+	}
+
+	var b bytes.Buffer
+	if err := s.render(&b); err != nil {
+		t.Fatal(err)
+	}
+	const condrestart = "    condrestart|try-restart)\n" +
+		"        if is_running; then\n" +
+		"            \"$0\" restart || exit 1\n" +
+		"        fi\n" +
+		"    ;;\n"
+	script := b.String()
+	if !strings.Contains(script, condrestart) || !strings.Contains(script, "|condrestart|try-restart|") {
+		t.Errorf("init script has no condrestart and try-restart case:\n%s", script)
+	}
+}
+
+func TestSysvControlFailure(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	script := filepath.Join(root, "test")
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// An installed init script that fails returns the CommandError.
+	_, restore := fakeCommandRunner(map[string]fakeResult{script + " start": {exitCode: 1}, script + " stop": {exitCode: 1}})
+	defer restore()
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionInitDir: root}}}
+	for _, f := range []func() error{s.Start, s.Stop} {
+		err := f()
+		if cmdErr, ok := err.(*CommandError); !ok || cmdErr.ExitCode != 1 {
+			t.Errorf("error = %v, want the CommandError of the init script", err)
+		}
+	}
+}
+
+func TestSysvStopStopped(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	script := filepath.Join(root, "test")
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		status  fakeResult
+		wantErr bool
+	}{
+		{"not running", fakeResult{1, "Stopped\n"}, false},
+		{"still running", fakeResult{0, "Running\n"}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, restore := fakeCommandRunner(map[string]fakeResult{
+				script + " stop":   {exitCode: 1},
+				script + " status": tt.status,
+			})
+			defer restore()
+
+			s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionInitDir: root}}}
+			err := s.Stop()
+			if cmdErr, ok := err.(*CommandError); tt.wantErr && (!ok || cmdErr.ExitCode != 1) {
+				t.Errorf("Stop() = %v, want the CommandError of the init script", err)
+			} else if !tt.wantErr && err != nil {
+				t.Errorf("Stop() = %v, want nil", err)
+			}
+			if got, want := strings.Join(*calls, ","), script+" stop,"+script+" status"; got != want {
+				t.Errorf("Stop() ran %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSysvAfterNetworkOnline(t *testing.T) {
+	for _, tt := range []struct {
+		online bool
+		start  string
+		stop   string
+	}{
+		{false, "\n# Required-Start:\n", "\n# Required-Stop:\n"},
+		{true, "\n# Required-Start:    $network $remote_fs\n", "\n# Required-Stop:     $network $remote_fs\n"},
+	} {
+		s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionAfterNetworkOnline: tt.online}}}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{tt.start, tt.stop} {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("AfterNetworkOnline=%v: init script is missing %q:\n%s", tt.online, want, b.String())
+			}
+		}
+	}
+}
+
+func TestSysvDisableAndStop(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	script := filepath.Join(root, "init.d", "servicetest-disable")
+	s := &sysv{Config: &Config{Name: "servicetest-disable", Option: KeyValue{optionInitDir: filepath.Dir(script)}}}
+	links, err := s.rcLinks(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range links {
+		if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(script, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls, restore := fakeCommandRunner(map[string]fakeResult{script + " stop": {}})
+	defer restore()
+	if err := s.DisableAndStop(); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range links {
+		if _, err := os.Lstat(link); !os.IsNotExist(err) {
+			t.Errorf("DisableAndStop() left %s, err = %v", link, err)
+		}
+	}
+	if got, want := strings.Join(*calls, ","), script+" stop"; got != want {
+		t.Errorf("DisableAndStop() ran %q, want %q", got, want)
+	}
+}
+
+func TestSysvRelativeLogDirectory(t *testing.T) {
+	tests := []struct {
+		name       string
+		workingDir string
+		logDir     string
+		want       string
+		wantErr    bool
+	}{
+		{"default", "", "", defaultLogDirectory, false},
+		{"absolute", "/srv/app", "/var/log/app", "/var/log/app", false},
+		{"relative", "/srv/app", "./logs", "/srv/app/logs", false},
+		{"parent", "/srv/app", "../logs", "/srv/logs", false},
+		{"relative without working directory", "", "logs", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			option := KeyValue{}
+			if tt.logDir != "" {
+				option[optionLogDirectory] = tt.logDir
+			}
+			s := &sysv{Config: &Config{Name: "test", WorkingDirectory: tt.workingDir, Option: option}}
+			var b bytes.Buffer
+			err := s.render(&b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if want := "\nstdout_log=\"" + tt.want + "/$name.log\"\n"; !strings.Contains(b.String(), want) {
+				t.Errorf("init script is missing %q:\n%s", want, b.String())
+			}
+		})
+	}
+}
+
+func TestSysvForceKill(t *testing.T) {
+	const kill = "kill -9 \"$(get_pid)\""
+	for _, force := range []bool{false, true} {
+		s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionForceKill: force}}}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		script := b.String()
+		if got := strings.Contains(script, kill); got != force {
+			t.Errorf("ForceKill=%v: init script contains %q = %v:\n%s", force, kill, got, script)
+		}
+		// Both modes still fail when the process survives, and clean up the
+		// pid file once it is gone.
+		for _, want := range []string{"\n                exit 1\n", "\n                    rm \"$pid_file\"\n"} {
+			if !strings.Contains(script, want) {
+				t.Errorf("ForceKill=%v: init script is missing %q:\n%s", force, want, script)
+			}
+		}
+	}
+}
+
+func TestSysvRestartPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  KeyValue
+		want    []string
+		wantErr bool
+	}{
+		{"unset", nil, nil, false},
+		{"no", KeyValue{optionRestartPolicy: restartPolicyNo}, nil, false},
+		{"always", KeyValue{optionRestartPolicy: restartPolicyAlways}, []string{
+			"\n            (supervise) >> \"$stdout_log\" 2>> \"$stderr_log\" &\n",
+			"\nchild_pid_file=\"$pid_file.child\"\n",
+			"\n        sleep 1 &\n",
+		}, false},
+		{"on-failure", KeyValue{optionRestartPolicy: restartPolicyOnFailure, optionRestartSec: 1500 * time.Millisecond}, []string{
+			"\n            (supervise) >> \"$stdout_log\" 2>> \"$stderr_log\" &\n",
+			"\n        if [ $? -eq 0 ]; then\n            break\n",
+			"\n        sleep 1.5 &\n",
+		}, false},
+		{"force kill", KeyValue{optionRestartPolicy: restartPolicyAlways, optionForceKill: true}, []string{
+			"kill -9 \"$(cat \"$child_pid_file\")\"",
+		}, false},
+		{"invalid", KeyValue{optionRestartPolicy: "sometimes"}, nil, true},
+		{"single instance", KeyValue{optionRestartPolicy: restartPolicyAlways, optionSingleInstance: true}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &sysv{Config: &Config{Name: "test", Option: tt.option}}
+			var b bytes.Buffer
+			err := s.render(&b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			script := b.String()
+			if tt.want == nil && (strings.Contains(script, "supervise") || strings.Contains(script, "child_pid_file")) {
+				t.Errorf("init script supervises the service without a restart policy:\n%s", script)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(script, want) {
+					t.Errorf("init script is missing %q:\n%s", want, script)
+				}
+			}
+			if tt.name == "always" && strings.Contains(script, "-eq 0 ]; then\n            break") {
+				t.Errorf("always stops supervising after a zero exit status:\n%s", script)
+			}
+		})
+	}
+}
+
+func TestSysvRestartPolicyReload(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	out := filepath.Join(root, "reload.out")
+	ready := filepath.Join(root, "ready")
+	app := filepath.Join(root, "app")
+	// app records a SIGHUP to out once its trap is set, sleep runs in the
+	// background so the trap fires at once.
+	appScript := "#!/bin/sh\ntrap 'echo reloaded > " + out + ".tmp; mv " + out + ".tmp " + out + "' HUP\ntouch " + ready + "\nwhile :; do sleep 1 & wait $!; done\n"
+	if err := ioutil.WriteFile(app, []byte(appScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+	pidFile := filepath.Join(root, "test.pid")
+	s := &sysv{Config: &Config{Name: "test", Executable: app, Option: KeyValue{
+		optionInitDir:       root,
+		optionEnabled:       false,
+		optionLogDirectory:  root,
+		optionPIDFile:       pidFile,
+		optionRestartPolicy: restartPolicyAlways,
+		optionReloadSignal:  "HUP",
+	}}}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(root, "test")
+	defer exec.Command("/bin/sh", script, "stop").Run()
+
+	if out, err := exec.Command("/bin/sh", script, "start").CombinedOutput(); err != nil {
+		t.Fatalf("start: %v\n%s", err, out)
+	}
+	waitUntil(5*time.Second, 10*time.Millisecond, func() bool {
+		_, err = os.Stat(ready)
+		return err == nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("/bin/sh", script, "reload").CombinedOutput(); err != nil {
+		t.Fatalf("reload: %v\n%s", err, out)
+	}
+	var got []byte
+	waitUntil(5*time.Second, 10*time.Millisecond, func() bool {
+		got, err = ioutil.ReadFile(out)
+		return err == nil
+	})
+	if string(got) != "reloaded\n" {
+		t.Errorf("the service recorded %q after reload, %v, want one SIGHUP", got, err)
+	}
+	if err := exec.Command("/bin/sh", script, "status").Run(); err != nil {
+		t.Errorf("status after reload: %v, want the supervisor still running", err)
+	}
+}
+
+func TestSysvInvalidScript(t *testing.T) {
+	defer func(s System) { system = s }(system)
+	system = linuxSystemService{
+		name:        "unix-systemv",
+		detect:      func() bool { return true },
+		interactive: func() bool { return true },
+		new:         newSystemVService,
+	}
+
+	_, err := New(nil, &Config{Name: "test", Option: KeyValue{optionSysvScript: "{{if .Name}}unterminated"}})
+	if err == nil || !strings.Contains(err.Error(), optionSysvScript) {
+		t.Fatalf("New() error = %v, want an invalid %s error", err, optionSysvScript)
+	}
+
+	s, err := New(nil, &Config{Name: "test", Option: KeyValue{optionSysvScript: "#!/bin/sh\n# {{.Name}}\n"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := s.(*sysv).render(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "#!/bin/sh\n# test\n"; got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+}
+
+func TestSysvLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionLogDirectory: dir}}}
+	if _, err := s.Logs(10); err == nil {
+		t.Fatal("Logs() without log files succeeded, want an error")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "test.log"), []byte("started\nserving\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "test.err"), []byte("warning\n"), 0644)
+	got, err := s.Logs(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "serving\nwarning\n"; string(got) != want {
+		t.Errorf("Logs() = %q, want %q", got, want)
+	}
+	var _ LogReader = s
+
+	// A stale .err from before CombinedOutput was set is not read.
+	s.Option[optionCombinedOutput] = true
+	got, err = s.Logs(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "serving\n"; string(got) != want {
+		t.Errorf("Logs() with CombinedOutput = %q, want %q", got, want)
+	}
+}
+
+func TestParseSysvProperties(t *testing.T) {
+	s := &sysv{Config: &Config{Name: "test", EnvVars: map[string]string{"APP_ENV": "prod"}}}
+	var b bytes.Buffer
+	if err := s.render(&b); err != nil {
+		t.Fatal(err)
+	}
+	got := parseSysvProperties(b.String())
+	for k, v := range map[string]string{
+		"pid_file":   filepath.Join(pidDir(), "test.pid"),
+		"stdout_log": defaultLogDirectory + "/$name.log",
+		"stderr_log": defaultLogDirectory + "/$name.err",
+		"APP_ENV":    "prod",
+	} {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if _, found := got["child"]; found {
+		t.Error("assignments inside functions are reported as properties")
+	}
+}
+
+func TestSysvUMask(t *testing.T) {
+	tests := []struct {
+		umask   string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"027", "\n            umask 027\n", false},
+		{"0007", "\n            umask 0007\n", false},
+		{"77", "", true},
+		{"089", "", true},
+		{"1777", "", true},
+		{"027; rm -rf /", "", true},
+	}
+	for _, tt := range tests {
+		option := KeyValue{}
+		if tt.umask != "" {
+			option[optionUMask] = tt.umask
+		}
+		s := &sysv{Config: &Config{Name: "test", Option: option}}
+		var b bytes.Buffer
+		err := s.render(&b)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("UMask=%q: render() error = %v, wantErr %v", tt.umask, err, tt.wantErr)
+		}
+		if err != nil {
+			continue
+		}
+		script := b.String()
+		if tt.want == "" && strings.Contains(script, "umask") {
+			t.Errorf("UMask=%q: init script sets a umask:\n%s", tt.umask, script)
+		}
+		if tt.want != "" && !strings.Contains(script, tt.want) {
+			t.Errorf("UMask=%q: init script is missing %q:\n%s", tt.umask, tt.want, script)
+		}
+	}
+}
+
+func TestSysvInitDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"init.d", "rc2.d", "rc6.d"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	initDir := filepath.Join(root, "init.d")
+	script := filepath.Join(initDir, "test")
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionInitDir: initDir}}}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(script); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []string{"rc2.d/S50test", "rc6.d/K02test"} {
+		if target, err := os.Readlink(filepath.Join(root, link)); err != nil || target != script {
+			t.Errorf("%s links to %q, %v, want %s", link, target, err, script)
+		}
+	}
+
+	// service only knows /etc/init.d, the script is run directly.
+	calls, restore := fakeCommandRunner(map[string]fakeResult{
+		script + " start":  {},
+		script + " status": {stdout: "Running\n"},
+	})
+	defer restore()
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := s.Status(); status != StatusRunning || err != nil {
+		t.Errorf("Status() = %v, %v, want %v", status, err, StatusRunning)
+	}
+	if got, want := strings.Join(*calls, ","), script+" start,"+script+" status"; got != want {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Disable(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "rc2.d/S50test")); !os.IsNotExist(err) {
+		t.Errorf("Disable() left rc2.d/S50test, err = %v", err)
+	}
+
+	s.Option[optionInitDir] = "init.d"
+	if _, err := s.configPath(); err == nil {
+		t.Error("configPath() with a relative InitDir succeeded, want an error")
+	}
+}
+
+func TestSysvStatusTimeout(t *testing.T) {
+	// The init script hangs until the command is canceled.
+	_, restore := fakeCommandFunc(func(ctx context.Context, line string) (fakeResult, error) {
+		<-ctx.Done()
+		return fakeResult{}, ctx.Err()
+	})
+	defer restore()
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionStatusTimeout: 50 * time.Millisecond}}}
+	start := time.Now()
+	status, err := s.Status()
+	if status != StatusUnknown || err == nil {
+		t.Fatalf("Status() = %v, %v, want %v and an error", status, err, StatusUnknown)
+	}
+	if cerr, ok := err.(*CommandError); !ok || cerr.Err != context.DeadlineExceeded {
+		t.Errorf("Status() error = %v, want a CommandError for %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Status() took %v, want the timeout", elapsed)
+	}
+}
+
+func TestSysvExpandArgEnv(t *testing.T) {
+	args := []string{"--home=$HOME", "${APP_DIR}/conf", "$(reboot)", "`reboot`", `a "b" \c`, "$1", "${APP_DIR:-x}"}
+	for _, tt := range []struct {
+		expand bool
+		want   string
+	}{
+		{false, ` '--home=$HOME' '${APP_DIR}/conf' '$(reboot)' '` + "`reboot`" + `' 'a "b" \c' '$1' '${APP_DIR:-x}'` + "\n"},
+		{true, ` "--home=$HOME" "${APP_DIR}/conf" "\$(reboot)" "` + "\\`reboot\\`" + `" "a \"b\" \\c" "\$1" "\${APP_DIR:-x}"` + "\n"},
+	} {
+		s := &sysv{Config: &Config{Name: "test", Executable: "/usr/bin/app", Arguments: args, Option: KeyValue{optionExpandArgEnv: tt.expand}}}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		want := "\n    exec '/usr/bin/app'" + tt.want
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("ExpandArgEnv=%v: init script is missing %q:\n%s", tt.expand, want, b.String())
+		}
+	}
+
+	// Only the environment references expand when the shell runs the words.
+	expand := tf["cmdExpand"].(func(string) string)
+	var words []string
+	for _, arg := range args {
+		words = append(words, expand(arg))
+	}
+	cmd := exec.Command("/bin/sh", "-c", `printf '%s\n' `+strings.Join(words, " "))
+	cmd.Env = []string{"HOME=/home/app", "APP_DIR=/srv/app"}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--home=/home/app\n/srv/app/conf\n$(reboot)\n`reboot`\na \"b\" \\c\n$1\n${APP_DIR:-x}\n"
+	if string(out) != want {
+		t.Errorf("shell expanded the arguments to %q, want %q", out, want)
+	}
+}
+
+func TestSysvCombinedStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "test.pid")
+	if err := ioutil.WriteFile(pidFile, []byte("4242\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		result fakeResult
+		want   ServiceStatus
+	}{
+		{fakeResult{0, "Running\n"}, ServiceStatus{State: StatusRunning, PID: 4242}},
+		{fakeResult{1, "Stopped\n"}, ServiceStatus{State: StatusStopped}},
+		{fakeResult{0, "\n"}, ServiceStatus{State: StatusUnknown, LastError: ErrNotInstalled}},
+	} {
+		_, restore := fakeCommandRunner(map[string]fakeResult{"service test status": tt.result})
+		s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionPIDFile: pidFile}}}
+		got := CombinedStatus(s)
+		restore()
+		if got != tt.want {
+			t.Errorf("status %q: CombinedStatus() = %+v, want %+v", tt.result.stdout, got, tt.want)
+		}
+	}
+
+	// Under RestartPolicy PIDFile holds the supervisor.
+	if err := ioutil.WriteFile(pidFile+".child", []byte("4343\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, restore := fakeCommandRunner(map[string]fakeResult{"service test status": {0, "Running\n"}})
+	defer restore()
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionPIDFile: pidFile, optionRestartPolicy: restartPolicyAlways}}}
+	if got := CombinedStatus(s); got.PID != 4343 {
+		t.Errorf("CombinedStatus() under RestartPolicy = %+v, want the PID 4343 of the service", got)
+	}
+}
+
+func TestSysvConditionPathExists(t *testing.T) {
+	const guard = "\n            if [ ! -e '/mnt/data' ]; then\n"
+	for _, path := range []string{"", "/mnt/data"} {
+		s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionConditionPathExists: path}}}
+		if path == "" {
+			s.Option = nil
+		}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		script := b.String()
+		i := strings.Index(script, guard)
+		if path == "" {
+			if i >= 0 || strings.Contains(script, "condition not met") {
+				t.Errorf("init script checks a path without ConditionPathExists:\n%s", script)
+			}
+			continue
+		}
+		start := strings.Index(script, "\n            start_cmd >>")
+		if i < 0 || start < 0 || i > start {
+			t.Errorf("init script does not check %s before starting:\n%s", path, script)
+		}
+		if !strings.Contains(script[i:], "\n                exit 0\n") {
+			t.Errorf("init script does not exit 0 when %s is missing:\n%s", path, script)
+		}
+	}
+}
+
+func TestSysvInstallExecCheck(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	notExec := filepath.Join(root, "app")
+	if err := ioutil.WriteFile(notExec, []byte("#!/bin/sh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		executable string
+		want       string
+	}{
+		{"missing", filepath.Join(root, "missing"), "no such file"},
+		{"directory", root, "not a regular file"},
+		{"not executable", notExec, "no executable bit"},
+	}
+	for _, tt := range tests {
+		s := &sysv{Config: &Config{Name: "test", Executable: tt.executable, Option: KeyValue{optionInitDir: root, optionEnabled: false}}}
+		if err := s.Install(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Install() = %v, want an error containing %q", tt.name, err, tt.want)
+		}
+		if _, err := os.Stat(filepath.Join(root, "test")); !os.IsNotExist(err) {
+			t.Errorf("%s: Install() wrote the init script, err = %v", tt.name, err)
+		}
+	}
+
+	s := &sysv{Config: &Config{Name: "test", Executable: filepath.Join(root, "missing"), Option: KeyValue{
+		optionInitDir:       root,
+		optionEnabled:       false,
+		optionSkipExecCheck: true,
+	}}}
+	if err := s.Install(); err != nil {
+		t.Errorf("Install() with SkipExecCheck = %v", err)
+	}
+}
+
+func TestSysvBackupOnInstall(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	script := filepath.Join(root, "test")
+	if err := ioutil.WriteFile(script, []byte("old\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionInitDir: root, optionEnabled: false}}}
+	if err := s.Install(); err == nil {
+		t.Fatal("Install() over an existing init script succeeded without BackupOnInstall")
+	}
+
+	s.Option[optionBackupOnInstall] = true
+	argsFile := filepath.Join(root, "test.args")
+	s.Option[optionArgsFile] = argsFile
+	s.Option[optionFileMode] = os.FileMode(0750)
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(argsFile); err != nil || fi.Mode().Perm() != 0640 {
+		t.Errorf("ArgsFile = %v, %v, want mode 0640 from FileMode 0750", fi, err)
+	}
+	backups, err := filepath.Glob(script + ".bak-*")
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups = %q, %v, want one", backups, err)
+	}
+	if suffix := strings.TrimPrefix(backups[0], script+".bak-"); len(suffix) != len(backupTimeFormat) {
+		t.Errorf("backup %s does not end in a timestamp", backups[0])
+	}
+	if b, err := ioutil.ReadFile(backups[0]); err != nil || string(b) != "old\n" {
+		t.Errorf("backup = %q, %v, want the previous init script", b, err)
+	}
+	if b, err := ioutil.ReadFile(script); err != nil || !strings.HasPrefix(string(b), "#!/bin/sh") {
+		t.Errorf("init script = %q, %v, want the new init script", b, err)
+	}
+
+	s.Option[optionRestoreOnUninstall] = true
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(script); err != nil || string(b) != "old\n" {
+		t.Errorf("init script after Uninstall() = %q, %v, want the backup", b, err)
+	}
+	if backups, _ := filepath.Glob(script + ".bak-*"); len(backups) != 0 {
+		t.Errorf("Uninstall() left backups %q", backups)
+	}
+	if _, err := os.Stat(argsFile); !os.IsNotExist(err) {
+		t.Errorf("Uninstall() restoring the backup left %s, err = %v", argsFile, err)
+	}
+}
+
+func TestSysvStartAfterInstall(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	script := filepath.Join(root, "test")
+
+	// The service runs until it is stopped.
+	running := false
+	calls, restore := fakeCommandFunc(func(ctx context.Context, line string) (fakeResult, error) {
+		switch line {
+		case script + " start":
+			running = true
+		case script + " stop":
+			running = false
+		case script + " status":
+			if !running {
+				return fakeResult{1, "Stopped\n"}, nil
+			}
+			return fakeResult{0, "Running\n"}, nil
+		}
+		return fakeResult{}, nil
+	})
+	defer restore()
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{
+		optionInitDir:             root,
+		optionEnabled:             false,
+		optionStartAfterInstall:   true,
+		optionStopBeforeUninstall: true,
+	}}}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(*calls, ","), script+" start"; got != want {
+		t.Errorf("Install() ran %q, want %q", got, want)
+	}
+	*calls = nil
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(*calls, ","), script+" status,"+script+" stop,"+script+" status"; got != want {
+		t.Errorf("Uninstall() ran %q, want %q", got, want)
+	}
+	if _, err := os.Stat(script); !os.IsNotExist(err) {
+		t.Errorf("Uninstall() left %s, err = %v", script, err)
+	}
+
+	// A failed start leaves the service installed.
+	_, restore = fakeCommandRunner(map[string]fakeResult{script + " start": {exitCode: 1}})
+	defer restore()
+	if err := s.Install(); err == nil {
+		t.Error("Install() = nil, want the start error")
+	}
+	if _, err := os.Stat(script); err != nil {
+		t.Errorf("Install() removed the init script after a failed start: %v", err)
+	}
+}
+
+func TestSysvEnvFile(t *testing.T) {
+	tests := []struct {
+		envFile string
+		want    string
+	}{
+		{"", "\n[ -e \"/etc/sysconfig/$name\" ] && . \"/etc/sysconfig/$name\"\n[ -e \"/etc/default/$name\" ] && . \"/etc/default/$name\"\n\nget_pid() {"},
+		{"/opt/app/env", "\n[ -e '/opt/app/env' ] && . '/opt/app/env'\n\nget_pid() {"},
+	}
+	for _, tt := range tests {
+		s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionEnvFile: tt.envFile}}}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		script := b.String()
+		if !strings.Contains(script, tt.want) {
+			t.Errorf("EnvFile=%q: init script is missing %q:\n%s", tt.envFile, tt.want, script)
+		}
+		if tt.envFile != "" && strings.Contains(script, "/etc/sysconfig") {
+			t.Errorf("EnvFile=%q: init script still sources /etc/sysconfig:\n%s", tt.envFile, script)
+		}
+	}
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionEnvFile: "env"}}}
+	if err := s.render(ioutil.Discard); err == nil {
+		t.Error("render() with a relative EnvFile succeeded, want an error")
+	}
+}
+
+func TestSysvInstalled(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	calls, restore := fakeCommandRunner(nil)
+	defer restore()
+	var _ InstallChecker = &sysv{}
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionInitDir: root, optionEnabled: false}}}
+	if installed, err := s.Installed(); installed || err != nil {
+		t.Errorf("Installed() = %v, %v before Install, want false, nil", installed, err)
+	}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if installed, err := s.Installed(); !installed || err != nil {
+		t.Errorf("Installed() = %v, %v after Install, want true, nil", installed, err)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("Installed() ran %q", *calls)
+	}
+
+	s.Option[optionUserService] = true
+	if _, err := s.Installed(); err != errNoUserServiceSystemV {
+		t.Errorf("Installed() for a user service = %v, want %v", err, errNoUserServiceSystemV)
+	}
+}
+
+func TestInstalledServices(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	_, restore := fakeCommandRunner(nil)
+	defer restore()
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionInitDir: root, optionEnabled: false, optionBackupOnInstall: true}}}
+	// Installing twice leaves a backup that must not be listed.
+	for i := 0; i < 2; i++ {
+		if err := s.Install(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "other"), []byte("#!/bin/sh\nexit 0\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := installedServices(root)
+	if err != nil || !reflect.DeepEqual(got, []string{"test"}) {
+		t.Errorf("installedServices() = %q, %v, want [test]", got, err)
+	}
+	if _, err := installedServices(filepath.Join(root, "missing")); !os.IsNotExist(err) {
+		t.Errorf("installedServices() for a missing dir = %v, want not exist", err)
+	}
+}
+
+func TestSysvChkconfigHeader(t *testing.T) {
+	tests := []struct {
+		option    KeyValue
+		chkconfig string
+		start     string
+		kill      string
+	}{
+		{nil, "# chkconfig: 2345 50 02\n", "rc2.d/S50test", "rc6.d/K02test"},
+		{KeyValue{optionSysVStartPriority: 90, optionSysVKillPriority: 10}, "# chkconfig: 2345 90 10\n", "rc2.d/S90test", "rc6.d/K10test"},
+		// chkconfig --add must not start a disabled service in any runlevel.
+		{KeyValue{optionEnabled: false}, "# chkconfig: - 50 02\n", "rc2.d/S50test", "rc6.d/K02test"},
+	}
+	for _, tt := range tests {
+		s := &sysv{Config: &Config{Name: "test", Option: tt.option}}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		script := b.String()
+		if !strings.Contains(script, tt.chkconfig) {
+			t.Errorf("%v: init script is missing %q:\n%s", tt.option, tt.chkconfig, script)
+		}
+		// chkconfig runlevels are those of the LSB Default-Start header.
+		if !strings.Contains(script, "# Default-Start:     2 3 4 5\n# Default-Stop:      0 1 6\n") {
+			t.Errorf("%v: LSB header does not match the chkconfig runlevels:\n%s", tt.option, script)
+		}
+		links, err := s.rcLinks("/etc/init.d/test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if links[0] != "/etc/"+tt.start || links[len(links)-1] != "/etc/"+tt.kill {
+			t.Errorf("%v: rcLinks() = %q, want %s and %s", tt.option, links, tt.start, tt.kill)
+		}
+	}
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionSysVStartPriority: 100}}}
+	if err := s.render(ioutil.Discard); err == nil {
+		t.Error("render() with SysVStartPriority 100 succeeded, want an error")
+	}
+	if _, err := s.rcLinks("/etc/init.d/test"); err == nil {
+		t.Error("rcLinks() with SysVStartPriority 100 succeeded, want an error")
+	}
+}
+
+func TestSysvCombinedOutput(t *testing.T) {
+	tests := []struct {
+		combined bool
+		want     string
+	}{
+		{false, `>> "$stdout_log" 2>> "$stderr_log" &`},
+		{true, `>> "$stdout_log" 2>&1 &`},
+	}
+	for _, tt := range tests {
+		s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionCombinedOutput: tt.combined}}}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		script := b.String()
+		if !strings.Contains(script, tt.want) {
+			t.Errorf("CombinedOutput=%v: init script is missing %q:\n%s", tt.combined, tt.want, script)
+		}
+		if tt.combined && strings.Contains(script, "stderr_log") {
+			t.Errorf("CombinedOutput=%v: init script still uses stderr_log:\n%s", tt.combined, script)
+		}
+	}
+}
+
+func TestSysvStartRetries(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := ioutil.WriteFile(filepath.Join(root, "test"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// The start command fails twice, then succeeds.
+	var attempts int
+	_, restore := fakeCommandFunc(func(ctx context.Context, line string) (fakeResult, error) {
+		attempts++
+		if attempts <= 2 {
+			return fakeResult{1, ""}, nil
+		}
+		return fakeResult{}, nil
+	})
+	defer restore()
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{
+		optionInitDir:         root,
+		optionStartRetries:    2,
+		optionStartRetryDelay: time.Millisecond,
+	}}}
+	if err := s.Start(); err != nil || attempts != 3 {
+		t.Errorf("Start() = %v after %d attempts, want nil after 3", err, attempts)
+	}
+
+	attempts = 0
+	s.Option[optionStartRetries] = 1
+	if err := s.Start(); err == nil || attempts != 2 {
+		t.Errorf("Start() = %v after %d attempts, want the last error after 2", err, attempts)
+	}
+
+	attempts = 0
+	s.Option = KeyValue{optionInitDir: root}
+	if err := s.Start(); err == nil || attempts != 1 {
+		t.Errorf("Start() without StartRetries = %v after %d attempts, want an error after 1", err, attempts)
+	}
+}
+
+func TestSysvTemplateData(t *testing.T) {
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{
+		optionSysvScript:   "#!/bin/sh\n# {{.Name}} in {{.Extra.region}}\n",
+		optionTemplateData: map[string]interface{}{"region": "eu-west-1", "Name": "ignored"},
+	}}}
+	var b bytes.Buffer
+	if err := s.render(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "#!/bin/sh\n# test in eu-west-1\n"; got != want {
+		t.Errorf("render() = %q, want %q", got, want)
+	}
+
+	s = &sysv{Config: &Config{Name: "test", Option: KeyValue{optionTemplateData: map[string]string{"region": "eu-west-1"}}}}
+	if err := s.render(ioutil.Discard); err == nil {
+		t.Error("render() with a map[string]string TemplateData succeeded, want an error")
+	}
+}
+
+func TestSysvForking(t *testing.T) {
+	const background = `start_cmd >> "$stdout_log" 2>> "$stderr_log" &` + "\n" + `            echo "$!" > "$pid_file"`
+	const foreground = `(start_cmd) >> "$stdout_log" 2>> "$stderr_log"` + "\n"
+	for _, forking := range []bool{false, true} {
+		s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionForking: forking}}}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		script := b.String()
+		if got := strings.Contains(script, foreground); got != forking {
+			t.Errorf("Forking=%v: init script runs start_cmd in the foreground = %v:\n%s", forking, got, script)
+		}
+		if got := strings.Contains(script, background); got == forking {
+			t.Errorf("Forking=%v: init script records $! = %v:\n%s", forking, got, script)
+		}
+	}
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionForking: true, optionRestartPolicy: "always"}}}
+	if err := s.render(ioutil.Discard); err == nil {
+		t.Error("render() with Forking and RestartPolicy succeeded, want an error")
+	}
+}
+
+func TestSysvStalePID(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	app := filepath.Join(dir, "app")
+	if err := ioutil.WriteFile(app, []byte("#!/bin/sh\nsleep 30\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	scriptPath := filepath.Join(dir, "test")
+
+	tests := []struct {
+		name   string
+		args   []string
+		path   string
+		option KeyValue
+		want   string
+	}{
+		{"service", []string{"/bin/sleep", "30"}, "/bin/sleep", nil, "Running\n"},
+		{"process name", []string{"renamed", "30"}, "/bin/sleep", nil, "Running\n"},
+		{"script", []string{app}, app, nil, "Running\n"},
+		{"recycled pid", []string{"/bin/sleep", "30"}, "/usr/bin/test-bin", nil, "Stopped\n"},
+		{"recycled supervisor pid", []string{scriptPath, "30"}, "/bin/sleep", KeyValue{optionRestartPolicy: "always"}, "Stopped\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(tt.args[0], tt.args[1:]...)
+			if tt.args[0] == "renamed" {
+				cmd = exec.Command("/bin/sleep", tt.args[1:]...)
+				cmd.Args[0] = tt.args[0]
+			}
+			if err := cmd.Start(); err != nil {
+				t.Skip(err)
+			}
+			defer cmd.Wait()
+			defer cmd.Process.Kill()
+			pidFile := filepath.Join(dir, "test.pid")
+			if err := ioutil.WriteFile(pidFile, []byte(strconv.Itoa(cmd.Process.Pid)), 0644); err != nil {
+				t.Fatal(err)
+			}
+			option := KeyValue{optionPIDFile: pidFile}
+			for k, v := range tt.option {
+				option[k] = v
+			}
+			s := &sysv{Config: &Config{Name: "test", Executable: tt.path, Option: option}}
+			var b bytes.Buffer
+			if err := s.render(&b); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(scriptPath, b.Bytes(), 0755); err != nil {
+				t.Fatal(err)
+			}
+			out, _ := exec.Command("/bin/sh", scriptPath, "status").Output()
+			if string(out) != tt.want {
+				t.Errorf("status = %q, want %q", out, tt.want)
+			}
+		})
+	}
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionRestartPolicy: "always"}}}
+	var b bytes.Buffer
+	if err := s.render(&b); err != nil {
+		t.Fatal(err)
+	}
+	if script := b.String(); !strings.Contains(script, `rm -f "$pid_file" "$child_pid_file"`) {
+		t.Errorf("init script does not remove stale pid files on start:\n%s", script)
+	}
+}
+
+func TestSysvSupervisorPid(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	s := &sysv{Config: &Config{Name: "test", Executable: "/bin/sleep", Arguments: []string{"30"}, Option: KeyValue{
+		optionLogDirectory:  root,
+		optionPIDFile:       filepath.Join(root, "test.pid"),
+		optionRestartPolicy: restartPolicyAlways,
+	}}}
+	var b bytes.Buffer
+	if err := s.render(&b); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(root, "test")
+	if err := ioutil.WriteFile(script, b.Bytes(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "S50test")
+	if err := os.Symlink(script, link); err != nil {
+		t.Fatal(err)
+	}
+	defer exec.Command("/bin/sh", script, "stop").Run()
+
+	// At boot the supervisor runs with the rc link as argv[1].
+	if out, err := exec.Command("/bin/sh", link, "start").CombinedOutput(); err != nil {
+		t.Fatalf("start: %v\n%s", err, out)
+	}
+	if out, _ := exec.Command("/bin/sh", script, "status").Output(); string(out) != "Running\n" {
+		t.Errorf("status = %q, want the supervisor started through the rc link to be running", out)
+	}
+}
+
+func TestSysvNice(t *testing.T) {
+	const ionice = "    ionice=\n" +
+		"    if command -v ionice > /dev/null 2>&1; then\n" +
+		"        ionice=\"ionice -c 2 -n 7\"\n" +
+		"    fi\n" +
+		"    # $ionice is split into its arguments, or is no word at all if empty.\n" +
+		"    # shellcheck disable=SC2086\n"
+	tests := []struct {
+		name    string
+		option  KeyValue
+		want    string
+		wantErr bool
+	}{
+		{"unset", KeyValue{}, "start_cmd() {\n    exec '/usr/bin/app'\n", false},
+		{"nice", KeyValue{optionNice: 10}, "start_cmd() {\n    exec nice -n 10 '/usr/bin/app'\n", false},
+		{"zero nice", KeyValue{optionNice: 0}, "exec nice -n 0 '/usr/bin/app'\n", false},
+		{"ionice", KeyValue{optionIONiceClass: 2, optionIONicePriority: 7}, "start_cmd() {\n" + ionice + "    exec $ionice '/usr/bin/app'\n", false},
+		{"both", KeyValue{optionNice: -5, optionIONiceClass: 3}, "    exec nice -n -5 $ionice '/usr/bin/app'\n", false},
+		{"nice too low", KeyValue{optionNice: -21}, "", true},
+		{"nice too high", KeyValue{optionNice: 20}, "", true},
+		{"bad class", KeyValue{optionIONiceClass: 4}, "", true},
+		{"bad priority", KeyValue{optionIONiceClass: 2, optionIONicePriority: 8}, "", true},
+		{"priority without class", KeyValue{optionIONicePriority: 4}, "", true},
+		{"priority with idle class", KeyValue{optionIONiceClass: 3, optionIONicePriority: 4}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &sysv{Config: &Config{Name: "test", Executable: "/usr/bin/app", Option: tt.option}}
+			var b bytes.Buffer
+			err := s.render(&b)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("render() with %v succeeded, want an error", tt.option)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			script := b.String()
+			if !strings.Contains(script, tt.want) {
+				t.Errorf("init script does not contain %q:\n%s", tt.want, script)
+			}
+			if out, err := exec.Command("sh", "-n", "-c", script).CombinedOutput(); err != nil {
+				t.Errorf("init script is not valid shell: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+func TestSysvInstallLinkErrors(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.Mkdir(filepath.Join(root, "init.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(root, "init.d", "test")
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionInitDir: filepath.Join(root, "init.d")}}}
+
+	// Without any start runlevel directory the service would never start.
+	if err := os.Mkdir(filepath.Join(root, "rc0.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	err = s.Install()
+	if err == nil || !strings.Contains(err.Error(), "no start runlevel symlink") {
+		t.Errorf("Install() without start runlevel directories = %v, want an error", err)
+	}
+	if installed, _ := s.Installed(); !installed {
+		t.Error("Install() removed the init script after failing to enable it")
+	}
+	if err := os.Remove(script); err != nil {
+		t.Fatal(err)
+	}
+
+	// rc3.d is a file, so its symlink can not be created even as root.
+	if err := os.Mkdir(filepath.Join(root, "rc2.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "rc3.d"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = s.Install()
+	if err == nil || !strings.Contains(err.Error(), "could not create 1 of 7 runlevel symlinks") || !strings.Contains(err.Error(), "rc3.d") {
+		t.Errorf("Install() with an unwritable runlevel directory = %v, want an error naming rc3.d", err)
+	}
+	if target, err := os.Readlink(filepath.Join(root, "rc2.d", "S50test")); err != nil || target != script {
+		t.Errorf("rc2.d/S50test links to %q, %v, want %s", target, err, script)
+	}
+}
+
+func TestSysvRunlevels(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"init.d", "rc0.d", "rc1.d", "rc2.d", "rc3.d", "rc4.d", "rc5.d", "rc6.d"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{
+		optionInitDir:        filepath.Join(root, "init.d"),
+		optionStartRunlevels: "3,5",
+	}}}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	links, err := filepath.Glob(filepath.Join(root, "rc?.d", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, link := range links {
+		rel, _ := filepath.Rel(root, link)
+		got = append(got, rel)
+	}
+	want := []string{"rc0.d/K02test", "rc1.d/K02test", "rc3.d/S50test", "rc5.d/S50test", "rc6.d/K02test"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Install() created %q, want %q", got, want)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(root, "init.d", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, header := range []string{"# chkconfig: 35 50 02\n", "# Default-Start:     3 5\n", "# Default-Stop:      0 1 6\n"} {
+		if !bytes.Contains(b, []byte(header)) {
+			t.Errorf("init script is missing %q:\n%s", header, b)
+		}
+	}
+
+	for _, option := range []KeyValue{
+		{optionStartRunlevels: "3,7"},
+		{optionStartRunlevels: "35"},
+		{optionStartRunlevels: "3,3"},
+		{optionStartRunlevels: ""},
+		{optionStopRunlevels: "0,1,5"},
+	} {
+		s := &sysv{Config: &Config{Name: "test", Option: option}}
+		if err := s.render(ioutil.Discard); err == nil {
+			t.Errorf("render() with %v succeeded, want an error", option)
+		}
+	}
+}
+
+func TestSysvShellcheck(t *testing.T) {
+	shellcheck, err := exec.LookPath("shellcheck")
+	if err != nil {
+		t.Skip("shellcheck is not installed")
+	}
+	for _, option := range []KeyValue{
+		{},
+		{optionRestartPolicy: "on-failure", optionForceKill: true, optionReloadSignal: "HUP"},
+		{optionForking: true, optionCombinedOutput: true, optionUMask: "027"},
+		{optionNice: 10, optionIONiceClass: 2, optionIONicePriority: 7, optionEnvFile: "/etc/test.env"},
+	} {
+		s := &sysv{Config: &Config{Name: "test", Executable: "/usr/bin/app", Arguments: []string{"-c", "/etc/app conf"}, Option: option}}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command(shellcheck, "--shell=sh", "--include=SC2086,SC2046", "-")
+		cmd.Stdin = &b
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("shellcheck of the init script with %v: %v\n%s", option, err, out)
+		}
+	}
+}
+
+func TestSysvInstallPlan(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"init.d", "rc2.d", "rc3.d", "rc6.d"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := filepath.Join(root, "init.d", "test")
+
+	var _ InstallPlanner = &sysv{}
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionInitDir: filepath.Join(root, "init.d")}}}
+	plan, err := s.InstallPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FileOp{
+		{Path: script, Action: FileCreate},
+		{Path: filepath.Join(root, "rc2.d", "S50test"), Action: FileSymlink, Target: script},
+		{Path: filepath.Join(root, "rc3.d", "S50test"), Action: FileSymlink, Target: script},
+		{Path: filepath.Join(root, "rc6.d", "K02test"), Action: FileSymlink, Target: script},
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("InstallPlan() = %+v, want %+v", plan, want)
+	}
+	if _, err := os.Stat(script); !os.IsNotExist(err) {
+		t.Errorf("InstallPlan() wrote %s, err = %v", script, err)
+	}
+
+	// The plan matches what Install does.
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(filepath.Join(root, "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != len(plan) {
+		t.Errorf("Install() created %q, want the %d files of the plan", files, len(plan))
+	}
+	for _, op := range plan {
+		if op.Action == FileSymlink {
+			if target, err := os.Readlink(op.Path); err != nil || target != op.Target {
+				t.Errorf("%s links to %q, %v, want %s", op.Path, target, err, op.Target)
+			}
+		} else if _, err := os.Stat(op.Path); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err := s.InstallPlan(); err == nil {
+		t.Error("InstallPlan() of an installed service succeeded, want the error of Install")
+	}
+	s.Option[optionBackupOnInstall] = true
+	plan, err = s.InstallPlan()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 2 || plan[0].Action != FileRename || plan[0].Path != script || !strings.HasPrefix(plan[0].Target, script+".bak-") || plan[1].Action != FileCreate {
+		t.Errorf("InstallPlan() with BackupOnInstall = %+v, want a rename and create of %s", plan, script)
+	}
+}
+
+func TestSysvChRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	jail := filepath.Join(root, "jail")
+	if err := os.MkdirAll(filepath.Join(jail, "usr", "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, chroot := range []string{"", jail} {
+		s := &sysv{Config: &Config{Name: "test", Executable: "/usr/bin/app", ChRoot: chroot, Option: KeyValue{optionNice: 5}}}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		script := b.String()
+		wrapped := strings.Contains(script, "    exec nice -n 5 chroot '"+jail+"' '/usr/bin/app'\n")
+		if got := strings.Contains(script, "chroot"); got != (chroot != "") || got != wrapped {
+			t.Errorf("ChRoot %q: init script runs chroot = %v:\n%s", chroot, got, script)
+		}
+	}
+
+	// The executable is checked within ChRoot, which must exist.
+	s := &sysv{Config: &Config{Name: "test", Executable: "/usr/bin/app", ChRoot: jail, Option: KeyValue{optionInitDir: root, optionEnabled: false}}}
+	if err := s.Install(); err == nil || !strings.Contains(err.Error(), filepath.Join(jail, "usr", "bin", "app")) {
+		t.Errorf("Install() without the executable in ChRoot = %v, want an error", err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(jail, "usr", "bin", "app"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	s.ChRoot = filepath.Join(root, "missing")
+	s.Option[optionSkipExecCheck] = true
+	s.Option[optionBackupOnInstall] = true
+	if err := s.Install(); err == nil || !strings.Contains(err.Error(), "chroot") {
+		t.Errorf("Install() with a missing ChRoot = %v, want a chroot error", err)
+	}
+}
+
+func TestSysvRootPrefix(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"etc/init.d", "etc/rc2.d", "etc/rc6.d"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	calls, restore := fakeCommandRunner(nil)
+	defer restore()
+	// The executable is not staged, it is not checked.
+	s := &sysv{Config: &Config{Name: "test", Executable: "/usr/bin/not-staged", Option: KeyValue{
+		optionRootPrefix:          root,
+		optionStartAfterInstall:   true,
+		optionStopBeforeUninstall: true,
+	}}}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(root, "etc/init.d/test")
+	if _, err := os.Stat(script); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []string{"etc/rc2.d/S50test", "etc/rc6.d/K02test"} {
+		if target, err := os.Readlink(filepath.Join(root, link)); err != nil || target != "/etc/init.d/test" {
+			t.Errorf("%s links to %q, %v, want the live /etc/init.d/test", link, target, err)
+		}
+	}
+	if installed, err := s.Installed(); !installed || err != nil {
+		t.Errorf("Installed() = %v, %v, want true, nil", installed, err)
+	}
+
+	if err := s.Disable(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(script); !os.IsNotExist(err) {
+		t.Errorf("Uninstall() left %s, err = %v", script, err)
+	}
+	if _, err := os.Lstat(filepath.Join(root, "etc/rc2.d/S50test")); !os.IsNotExist(err) {
+		t.Errorf("Disable() left etc/rc2.d/S50test, err = %v", err)
+	}
+	if len(*calls) != 0 {
+		t.Errorf("staged Install and Uninstall ran %q", *calls)
+	}
+
+	s.Option[optionRootPrefix] = "stage"
+	if err := s.Install(); err == nil {
+		t.Error("Install() with a relative RootPrefix succeeded, want an error")
+	}
+}
+
+func TestSysvReinstall(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	script := filepath.Join(root, "test")
+
+	s := &sysv{Config: &Config{Name: "test", Arguments: []string{"-old"}, Option: KeyValue{optionInitDir: root, optionEnabled: false}}}
+	var _ Reinstaller = s
+	// Not installed yet, Reinstall installs.
+	if err := s.Reinstall(); err != nil {
+		t.Fatal(err)
+	}
+	old, err := ioutil.ReadFile(script)
+	if err != nil || !strings.Contains(string(old), "'-old'") {
+		t.Fatalf("init script = %q, %v, want the -old argument", old, err)
+	}
+
+	// A render error leaves the installed script and no temporary file.
+	s.Arguments = []string{"-new"}
+	s.Option[optionUMask] = "bad"
+	if err := s.Reinstall(); err == nil {
+		t.Fatal("Reinstall() with an invalid UMask succeeded, want an error")
+	}
+	if b, err := ioutil.ReadFile(script); err != nil || string(b) != string(old) {
+		t.Errorf("init script after a failed Reinstall() changed, err = %v", err)
+	}
+	if files, _ := ioutil.ReadDir(root); len(files) != 1 {
+		t.Errorf("Reinstall() left %d files, want only the init script", len(files))
+	}
+
+	delete(s.Option, optionUMask)
+	if err := s.Reinstall(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(script); err != nil || !strings.Contains(string(b), "'-new'") {
+		t.Errorf("init script = %q, %v, want the -new argument", b, err)
+	}
+	if fi, err := os.Stat(script); err != nil {
+		t.Error(err)
+	} else if fi.Mode().Perm() != 0755 {
+		t.Errorf("init script mode = %v, want 0755", fi.Mode())
+	}
+}
+
+func TestSysvPIDDir(t *testing.T) {
+	defer func(stat func(string) (os.FileInfo, error)) { pidDirStat = stat }(pidDirStat)
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range []struct {
+		hasRun bool
+		want   string
+	}{
+		{true, "/run/test.pid"},
+		{false, "/var/run/test.pid"},
+	} {
+		pidDirStat = func(name string) (os.FileInfo, error) {
+			if name == "/run" && tt.hasRun {
+				return os.Stat(dir)
+			}
+			return nil, os.ErrNotExist
+		}
+		s := &sysv{Config: &Config{Name: "test"}}
+		if got := s.pidFile(); got != tt.want {
+			t.Errorf("/run exists = %v: pidFile() = %q, want %q", tt.hasRun, got, tt.want)
+		}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		if want := "\npid_file='" + tt.want + "'\n"; !strings.Contains(b.String(), want) {
+			t.Errorf("/run exists = %v: init script is missing %q", tt.hasRun, want)
+		}
+
+		s.Option = KeyValue{optionPIDFile: "/srv/app.pid"}
+		if got := s.pidFile(); got != "/srv/app.pid" {
+			t.Errorf("pidFile() with PIDFile = %q, want /srv/app.pid", got)
+		}
+	}
+}
+
+func TestSysvUninstallKill(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	script := filepath.Join(root, "test")
+	pidFile := filepath.Join(root, "test.pid")
+
+	sleep := exec.Command("/bin/sleep", "30")
+	if err := sleep.Start(); err != nil {
+		t.Skip(err)
+	}
+	exited := make(chan struct{})
+	go func() {
+		sleep.Wait()
+		close(exited)
+	}()
+	defer sleep.Process.Kill()
+	if err := ioutil.WriteFile(pidFile, []byte(strconv.Itoa(sleep.Process.Pid)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The init script can not stop the service, it runs until it is killed.
+	_, restore := fakeCommandFunc(func(ctx context.Context, line string) (fakeResult, error) {
+		switch {
+		case strings.HasSuffix(line, " stop"):
+			return fakeResult{1, ""}, nil
+		case strings.HasSuffix(line, " status"):
+			select {
+			case <-exited:
+				return fakeResult{1, "Stopped\n"}, nil
+			default:
+				return fakeResult{0, "Running\n"}, nil
+			}
+		}
+		return fakeResult{}, nil
+	})
+	defer restore()
+
+	s := &sysv{Config: &Config{Name: "test", Executable: "/bin/sleep", Option: KeyValue{
+		optionInitDir:             root,
+		optionEnabled:             false,
+		optionPIDFile:             pidFile,
+		optionStopBeforeUninstall: true,
+		optionStopTimeout:         200 * time.Millisecond,
+	}}}
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Without a valid pid the service can not be killed and stays installed.
+	if err := ioutil.WriteFile(pidFile+".tmp", []byte("none\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s.Option[optionPIDFile] = pidFile + ".tmp"
+	if err := s.Uninstall(); err == nil || !strings.Contains(err.Error(), "could not kill") {
+		t.Errorf("Uninstall() with an invalid pid = %v, want a kill error", err)
+	}
+	if _, err := os.Stat(script); err != nil {
+		t.Errorf("Uninstall() removed the init script of a running service: %v", err)
+	}
+
+	s.Option[optionPIDFile] = pidFile
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-exited:
+	default:
+		t.Error("Uninstall() did not kill the service")
+	}
+	if _, err := os.Stat(script); !os.IsNotExist(err) {
+		t.Errorf("Uninstall() left %s, err = %v", script, err)
+	}
+}
+
+func TestSysvKillForeignPid(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	pidFile := filepath.Join(root, "test.pid")
+
+	// sleep reused the pids of a service that died.
+	sleep := exec.Command("/bin/sleep", "30")
+	if err := sleep.Start(); err != nil {
+		t.Skip(err)
+	}
+	defer sleep.Process.Kill()
+	for _, path := range []string{pidFile, pidFile + ".child"} {
+		if err := ioutil.WriteFile(path, []byte(strconv.Itoa(sleep.Process.Pid)+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &sysv{Config: &Config{Name: "test", Executable: "/usr/bin/app", Option: KeyValue{
+		optionInitDir:       root,
+		optionPIDFile:       pidFile,
+		optionRestartPolicy: restartPolicyAlways,
+	}}}
+	if err := s.kill(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sleep.Process.Signal(syscall.Signal(0)); err != nil {
+		t.Errorf("kill() killed a process that is not the service: %v", err)
+	}
+	if s.ownsPid(sleep.Process.Pid, false) || s.ownsPid(sleep.Process.Pid, true) {
+		t.Errorf("ownsPid(%d) = true for /bin/sleep, want false", sleep.Process.Pid)
+	}
+	s.Executable = "/bin/sleep"
+	if !s.ownsPid(sleep.Process.Pid, false) {
+		t.Errorf("ownsPid(%d) = false with Executable /bin/sleep, want true", sleep.Process.Pid)
+	}
+
+	// Until it is waited for the killed sleep is a zombie with an empty cmdline.
+	sleep.Process.Kill()
+	waitUntil(5*time.Second, 10*time.Millisecond, func() bool {
+		b, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", sleep.Process.Pid))
+		return err == nil && len(b) == 0
+	})
+	if s.ownsPid(sleep.Process.Pid, false) || s.ownsPid(sleep.Process.Pid, true) {
+		t.Errorf("ownsPid(%d) = true for a zombie, want false", sleep.Process.Pid)
+	}
+	sleep.Wait()
+}
+
+func TestSysvWaitForStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pidFile := filepath.Join(dir, "test.pid")
+	ioutil.WriteFile(pidFile, []byte("4242\n"), 0644)
+
+	// The service is still running for the first polls after stop.
+	var polls, runningPolls int
+	var calls []string
+	_, restore := fakeCommandFunc(func(ctx context.Context, line string) (fakeResult, error) {
+		action := line[strings.LastIndex(line, " ")+1:]
+		calls = append(calls, action)
+		if action != "status" {
+			return fakeResult{}, nil
+		}
+		if polls++; polls <= runningPolls {
+			return fakeResult{0, "Running\n"}, nil
+		}
+		return fakeResult{1, "Stopped\n"}, nil
+	})
+	defer restore()
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{
+		optionPIDFile:      pidFile,
+		optionRestartDelay: time.Duration(0),
+		optionStopTimeout:  5 * time.Second,
+	}}}
+	runningPolls = 3
+	if err := s.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(calls, ","), "stop,status,status,status,status,start"; got != want {
+		t.Errorf("Restart() ran %q, want %q", got, want)
+	}
+
+	polls, runningPolls = 0, 1000
+	err = s.waitForStop(200 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "pid 4242") {
+		t.Errorf("waitForStop() = %v, want a timeout error with pid 4242", err)
+	}
+}
+
+type closeRecordingLogger struct {
+	Logger
+	closed bool
+}
+
+func (l *closeRecordingLogger) Close() error {
+	l.closed = true
+	return nil
+}
+
+type flushRecordingLogger struct {
+	closeRecordingLogger
+	flushed bool
+}
+
+func (l *flushRecordingLogger) Flush() error {
+	l.flushed = true
+	return nil
+}
+
+func TestSysvRunFlushesLoggers(t *testing.T) {
+	s := &sysv{i: &signalTestService{}, Config: &Config{Name: "test", Option: KeyValue{optionRunWait: func() {}}}}
+	closer := &closeRecordingLogger{Logger: ConsoleLogger}
+	flusher := &flushRecordingLogger{closeRecordingLogger: closeRecordingLogger{Logger: ConsoleLogger}}
+	s.loggers.add(closer, nil)
+	s.loggers.add(flusher, nil)
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !closer.closed {
+		t.Error("Run() did not close the io.Closer Logger")
+	}
+	if !flusher.flushed || flusher.closed {
+		t.Errorf("Run() flushed = %v, closed = %v the Flusher Logger, want only flushed", flusher.flushed, flusher.closed)
+	}
+
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s.Option = KeyValue{optionSystemLoggerBackend: systemLoggerFile, optionLogDirectory: dir, optionRunWait: func() {}}
+	l, err := s.SystemLogger(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.loggers.loggers) != 1 {
+		t.Errorf("SystemLogger() recorded %d Loggers, want 1", len(s.loggers.loggers))
+	}
+	if err := l.Info("before Run"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Info("after Run"); err == nil {
+		t.Error("file Logger still writes after Run(), want its file closed")
+	}
+}
+
+func TestSysvShell(t *testing.T) {
+	for _, tt := range []struct {
+		shell string
+		want  string
+	}{
+		{"", "#!/bin/sh\n"},
+		{"/bin/bash", "#!/bin/bash\n"},
+	} {
+		s := &sysv{Config: &Config{Name: "test"}}
+		if tt.shell != "" {
+			s.Option = KeyValue{optionShell: tt.shell}
+		}
+		var b bytes.Buffer
+		if err := s.render(&b); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(b.String(), tt.want) {
+			t.Errorf("Shell=%q: init script starts with %q, want %q", tt.shell, strings.SplitN(b.String(), "\n", 2)[0], tt.want)
+		}
+	}
+
+	for _, shell := range []string{"bash", "/bin/sh -e"} {
+		s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionShell: shell}}}
+		if err := s.render(ioutil.Discard); err == nil {
+			t.Errorf("render() with Shell %q succeeded, want an error", shell)
+		}
+	}
+}
+
+func TestSysvInstallFileMode(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	c := &Config{Name: "test", Option: KeyValue{optionInitDir: root, optionEnabled: false, optionFileMode: "0750"}}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s := &sysv{Config: c}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(filepath.Join(root, "test")); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm() != 0750 {
+		t.Errorf("init script mode = %v, want 0750", fi.Mode().Perm())
+	}
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Option[optionFileMode] = os.FileMode(0644)
+	if err := s.Install(); err == nil {
+		t.Error("Install() with FileMode 0644 succeeded, want an error")
+	}
+}
+
+func TestSysvServiceCommandFallback(t *testing.T) {
+	hasService := true
+	calls, restore := fakeCommandFunc(func(ctx context.Context, line string) (fakeResult, error) {
+		if strings.HasPrefix(line, "service ") && !hasService {
+			return fakeResult{}, &exec.Error{Name: "service", Err: exec.ErrNotFound}
+		}
+		return fakeResult{0, "Running\n"}, nil
+	})
+	defer restore()
+
+	tests := []struct {
+		name       string
+		hasService bool
+		prefer     bool
+		want       string
+	}{
+		{"service", true, false, "service test start,service test status"},
+		{"no service", false, false, "service test start,/etc/init.d/test start,service test status,/etc/init.d/test status"},
+		{"prefer init script", true, true, "/etc/init.d/test start,/etc/init.d/test status"},
+	}
+	for _, tt := range tests {
+		*calls, hasService = nil, tt.hasService
+		s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionPreferInitScript: tt.prefer}}}
+		if err := s.Start(); err != nil {
+			t.Fatalf("%s: Start() = %v", tt.name, err)
+		}
+		if status, err := s.Status(); status != StatusRunning || err != nil {
+			t.Errorf("%s: Status() = %v, %v, want %v", tt.name, status, err, StatusRunning)
+		}
+		if got := strings.Join(*calls, ","); got != tt.want {
+			t.Errorf("%s: ran %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSysvServiceCommand(t *testing.T) {
+	calls, restore := fakeCommandRunner(map[string]fakeResult{
+		"/opt/bin/svc test start":  {},
+		"/opt/bin/svc test status": {0, "Running\n"},
+	})
+	defer restore()
+
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionServiceCommand: "/opt/bin/svc"}}}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := s.Status(); status != StatusRunning || err != nil {
+		t.Errorf("Status() = %v, %v, want %v", status, err, StatusRunning)
+	}
+	if got, want := strings.Join(*calls, ","), "/opt/bin/svc test start,/opt/bin/svc test status"; got != want {
+		t.Errorf("ran %q, want %q", got, want)
+	}
+
+	// Unlike service, a ServiceCommand that is not found is not replaced by the init script.
+	ran, restoreNotFound := fakeCommandFunc(func(ctx context.Context, line string) (fakeResult, error) {
+		return fakeResult{}, &exec.Error{Name: line, Err: exec.ErrNotFound}
+	})
+	defer restoreNotFound()
+	if err := s.Start(); err == nil {
+		t.Error("Start() without ServiceCommand succeeded, want an error")
+	}
+	if len(*ran) != 1 {
+		t.Errorf("Start() ran %q, want only /opt/bin/svc", *ran)
+	}
+}
+
+func TestSysvOnOperation(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	script := filepath.Join(root, "test")
+	if err := ioutil.WriteFile(script, []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	type operation struct {
+		op  string
+		err error
+	}
+	var ops []operation
+	defer func() { OnOperation = nil }()
+	OnOperation = func(op string, d time.Duration, err error) {
+		if d < 0 {
+			t.Errorf("OnOperation(%q) duration = %v", op, d)
+		}
+		ops = append(ops, operation{op, err})
+	}
+
+	_, restore := fakeCommandRunner(map[string]fakeResult{
+		script + " start":  {exitCode: 1},
+		script + " stop":   {},
+		script + " status": {0, "Running\n"},
+	})
+	defer restore()
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionInitDir: root, optionRestartDelay: time.Duration(0)}}}
+	startErr := s.Start()
+	if startErr == nil {
+		t.Fatal("Start() succeeded, want the failure of the init script")
+	}
+	s.Status()
+	s.Restart()
+
+	want := []operation{
+		{"start", startErr},
+		{"status", nil},
+		{"stop", nil},
+		{"start", startErr},
+		{"restart", startErr},
+	}
+	if len(ops) != len(want) {
+		t.Fatalf("OnOperation got %v, want %v", ops, want)
+	}
+	for i := range want {
+		if ops[i].op != want[i].op || (ops[i].err == nil) != (want[i].err == nil) {
+			t.Errorf("OnOperation call %d = %v, want %v", i, ops[i], want[i])
+		}
+	}
+}
+
+func TestSysvArgsFile(t *testing.T) {
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	out := filepath.Join(root, "args.out")
+	app := filepath.Join(root, "app")
+	// app writes its arguments NUL separated to out.
+	appScript := "#!/bin/sh\nfor a in \"$@\"; do printf '%s\\0' \"$a\"; done > " + out + ".tmp\nmv " + out + ".tmp " + out + "\n"
+	if err := ioutil.WriteFile(app, []byte(appScript), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"", "with space", "it's", `"double"`, "$HOME", "${PATH}", "`id`", "a\\b", "new\nline", "*", "-", "--flag=1"}
+	for i := 0; len(args) < 500; i++ {
+		args = append(args, fmt.Sprintf("--option-%d=value %d", i, i))
+	}
+	argsFile := filepath.Join(root, "test.args")
+	s := &sysv{Config: &Config{Name: "test", Executable: app, Arguments: args, Option: KeyValue{
+		optionInitDir:      root,
+		optionEnabled:      false,
+		optionArgsFile:     argsFile,
+		optionLogDirectory: root,
+		optionPIDFile:      filepath.Join(root, "test.pid"),
+	}}}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	script, err := ioutil.ReadFile(filepath.Join(root, "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(script, []byte("--option-1=")) || !bytes.Contains(script, []byte(" \"$@\"\n")) {
+		t.Errorf("init script lists the arguments instead of reading %s:\n%s", argsFile, script)
+	}
+	if fi, err := os.Stat(argsFile); err != nil || fi.Mode().Perm() != 0644 {
+		t.Errorf("ArgsFile = %v, %v, want mode 0644", fi, err)
+	}
+
+	exec.Command("/bin/sh", filepath.Join(root, "test"), "start").Run()
+	var got []byte
+	waitUntil(5*time.Second, 10*time.Millisecond, func() bool {
+		got, err = ioutil.ReadFile(out)
+		return err == nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Join(args, "\x00") + "\x00"; string(got) != want {
+		t.Errorf("the service got arguments %q, want %q", strings.Split(string(got), "\x00"), args)
+	}
+
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(argsFile); !os.IsNotExist(err) {
+		t.Errorf("Uninstall() left %s, err = %v", argsFile, err)
+	}
+	s.Option[optionArgsFile] = "test.args"
+	if err := s.render(ioutil.Discard); err == nil {
+		t.Error("render() with a relative ArgsFile succeeded, want an error")
+	}
+}
+
+func TestSysvLogMaxSize(t *testing.T) {
+	const check = `if [ -f "$log" ] && [ "$(wc -c < "$log")" -gt 1048576 ]; then`
+	tests := []struct {
+		name    string
+		option  KeyValue
+		want    []string
+		wantErr bool
+	}{
+		{"unset", KeyValue{}, nil, false},
+		{"zero", KeyValue{optionLogMaxSize: 0}, nil, false},
+		{"set", KeyValue{optionLogMaxSize: 1 << 20}, []string{`for log in "$stdout_log" "$stderr_log"`, check, `mv -f "$log" "$log.1"`}, false},
+		{"combined output", KeyValue{optionLogMaxSize: 1 << 20, optionCombinedOutput: true}, []string{"for log in \"$stdout_log\"\n", check}, false},
+		{"negative", KeyValue{optionLogMaxSize: -1}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &sysv{Config: &Config{Name: "test", Executable: "/usr/bin/app", Option: tt.option}}
+			var b bytes.Buffer
+			err := s.render(&b)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("render() with %v succeeded, want an error", tt.option)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			script := b.String()
+			if tt.want == nil && strings.Contains(script, "wc -c") {
+				t.Errorf("init script has a log size check without %s:\n%s", optionLogMaxSize, script)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(script, want) {
+					t.Errorf("init script does not contain %q:\n%s", want, script)
+				}
+			}
+			if out, err := exec.Command("sh", "-n", "-c", script).CombinedOutput(); err != nil {
+				t.Errorf("init script is not valid shell: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+func TestSysvTemplate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	shared := template.Must(template.New("").Funcs(template.FuncMap{"upper": strings.ToUpper}).Parse("#!/bin/sh\n# {{upper .Name}} runs {{.Path}}\n"))
+	for _, name := range []string{"one", "two"} {
+		s := &sysv{Config: &Config{Name: name, Executable: "/usr/bin/app", Option: KeyValue{
+			optionInitDir:       dir,
+			optionEnabled:       false,
+			optionSkipExecCheck: true,
+			optionSysvScript:    "ignored",
+			optionSysvTemplate:  shared,
+		}}}
+		if err := s.Install(); err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "#!/bin/sh\n# " + strings.ToUpper(name) + " runs /usr/bin/app\n"; string(b) != want {
+			t.Errorf("init script of %s = %q, want %q", name, b, want)
+		}
+	}
+
+	// The template fails when executed, Install must not write a script.
+	broken := template.Must(template.New("").Parse("#!/bin/sh\n{{.Missing}}\n"))
+	s := &sysv{Config: &Config{Name: "broken", Executable: "/usr/bin/app", Option: KeyValue{optionInitDir: dir, optionEnabled: false, optionSkipExecCheck: true, optionSysvTemplate: broken}}}
+	if err := s.Install(); err == nil {
+		t.Error("Install() with a template that does not execute succeeded, want an error")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "broken")); !os.IsNotExist(err) {
+		t.Errorf("Install() with a broken template left an init script, err = %v", err)
+	}
+
+	if _, err := newSystemVService(nil, "test", &Config{Name: "test", Option: KeyValue{optionSysvTemplate: "#!/bin/sh\n"}}); err == nil {
+		t.Errorf("newSystemVService() with a string %s succeeded, want an error", optionSysvTemplate)
+	}
+}
+
+func TestSysvMonit(t *testing.T) {
+	s := &sysv{Config: &Config{Name: "test", Option: KeyValue{optionPIDFile: "/run/test app.pid"}}}
+	var b bytes.Buffer
+	if err := s.renderMonit(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Managed-By: github.com/kardianos/service\n" +
+		"check process test with pidfile \"/run/test app.pid\"\n" +
+		"    start program = \"/etc/init.d/test start\"\n" +
+		"    stop program = \"/etc/init.d/test stop\"\n"
+	if b.String() != want {
+		t.Errorf("monit fragment =\n%s\nwant\n%s", b.String(), want)
+	}
+	s.Option[optionPIDFile] = "/run/\"test\".pid"
+	if err := s.renderMonit(ioutil.Discard); err == nil {
+		t.Error("renderMonit() with a quote in PIDFile succeeded, want an error")
+	}
+
+	root, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"init.d", "monit.d"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fragment := filepath.Join(root, "monit.d", "test.conf")
+	s = &sysv{Config: &Config{Name: "test", Executable: "/usr/bin/app", Option: KeyValue{
+		optionInitDir:          filepath.Join(root, "init.d"),
+		optionEnabled:          false,
+		optionSkipExecCheck:    true,
+		optionMonitIntegration: true,
+		optionMonitDir:         filepath.Join(root, "monit.d"),
+	}}}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(fragment); err != nil || !strings.Contains(string(b), filepath.Join(root, "init.d", "test")+" start") {
+		t.Errorf("Install() wrote %s = %q, %v, want it to start the init script", fragment, b, err)
+	}
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fragment); !os.IsNotExist(err) {
+		t.Errorf("Uninstall() left %s, err = %v", fragment, err)
+	}
+
+	// Restoring the previous init script removes the fragment too.
+	script := filepath.Join(root, "init.d", "test")
+	if err := ioutil.WriteFile(script, []byte("old\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	s.Option[optionBackupOnInstall] = true
+	s.Option[optionRestoreOnUninstall] = true
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(script); err != nil || string(b) != "old\n" {
+		t.Errorf("init script after Uninstall() = %q, %v, want the backup", b, err)
+	}
+	if _, err := os.Stat(fragment); !os.IsNotExist(err) {
+		t.Errorf("Uninstall() restoring the backup left %s, err = %v", fragment, err)
+	}
+}
+
+func TestSysvVerify(t *testing.T) {
+	newSysv := func(option KeyValue) *sysv {
+		return &sysv{Config: &Config{Name: "test", Executable: "/usr/bin/app", Arguments: []string{"-c", "/etc/app conf"}, Option: option}}
+	}
+	if err := newSysv(nil).Verify(); err != nil {
+		t.Errorf("Verify() of the built-in script = %v, want nil", err)
+	}
+
+	err := newSysv(KeyValue{optionSysvScript: "#!/bin/sh\necho {{.Name}}\nif true; then\n    echo unterminated\n"}).Verify()
+	if err == nil {
+		t.Fatal("Verify() of a script missing fi succeeded, want a syntax error")
+	}
+	if !strings.Contains(err.Error(), "does not parse") {
+		t.Errorf("Verify() = %v, want a syntax error", err)
+	}
+
+	err = newSysv(KeyValue{optionSysvScript: "#!/bin/sh\necho {{.Name}}\necho )\necho done\n"}).Verify()
+	if err == nil || !strings.Contains(err.Error(), "    3 | echo )") {
+		t.Errorf("Verify() = %v, want the error to quote line 3", err)
+	}
+
+	if err := newSysv(KeyValue{optionSysvScript: "#!/bin/sh\n{{.Missing}}\n"}).Verify(); err == nil {
+		t.Error("Verify() of a template that does not execute succeeded, want an error")
+	}
+}
@@ -84,3 +84,55 @@ func (p *program) Stop(s service.Service) error {
 	p.numStopped++
 	return nil
 }
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		option  service.KeyValue
+		want    time.Duration
+		wantErr bool
+	}{
+		{"unset", nil, 0, false},
+		{"string", service.KeyValue{"RestartDelay": "30s"}, 30 * time.Second, false},
+		{"duration", service.KeyValue{"RestartDelay": 2 * time.Minute}, 2 * time.Minute, false},
+		{"typo", service.KeyValue{"RestartDelay": "30ss"}, 0, true},
+		{"negative", service.KeyValue{"RestartDelay": "-1s"}, 0, true},
+		{"wrong type", service.KeyValue{"RestartDelay": 30}, 0, true},
+		{"wait timeout typo", service.KeyValue{"WaitForServiceTimeout": "1 minute"}, 0, true},
+		{"on failure delay", service.KeyValue{"OnFailureDelayDuration": "5s"}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &service.Config{Name: "test", Option: tt.option}
+			err := c.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if _, err := service.New(&program{}, c); err == nil {
+					t.Error("New() succeeded with an invalid option")
+				}
+				return
+			}
+			if tt.want != 0 && c.Option["RestartDelay"] != tt.want {
+				t.Errorf("RestartDelay normalized to %#v, want %v", c.Option["RestartDelay"], tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigValidateFileMode(t *testing.T) {
+	c := &service.Config{Name: "test", Option: service.KeyValue{"FileMode": "0750"}}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Option["FileMode"]; got != os.FileMode(0750) {
+		t.Errorf("FileMode normalized to %#v, want os.FileMode(0750)", got)
+	}
+	for _, mode := range []string{"0958", "rwxr-x---", "01777"} {
+		c := &service.Config{Name: "test", Option: service.KeyValue{"FileMode": mode}}
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate() with FileMode %q succeeded, want an error", mode)
+		}
+	}
+}
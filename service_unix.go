@@ -2,25 +2,160 @@
 // Use of this source code is governed by a zlib-style
 // license that can be found in the LICENSE file.
 
-//go:build linux || darwin || solaris || aix || freebsd
-// +build linux darwin solaris aix freebsd
+//go:build linux || darwin || solaris || aix || freebsd || openbsd
+// +build linux darwin solaris aix freebsd openbsd
 
 package service
 
 import (
-	"bytes"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log/syslog"
-	"os/exec"
+	"os"
+	"os/signal"
+	"os/user"
+	"strconv"
+	"strings"
 	"syscall"
+	"unsafe"
 )
 
 const defaultLogDirectory = "/var/log"
 
-func newSysLogger(name string, errs chan<- error) (Logger, error) {
-	w, err := syslog.New(syslog.LOG_INFO, name)
+// hasRootPrivileges reports whether the process can install system services.
+var hasRootPrivileges = func() bool {
+	return os.Geteuid() == 0
+}
+
+// preferUserService reports whether a service configured with kv should be
+// a user service.
+func preferUserService(kv KeyValue) bool {
+	if kv.bool(optionUserService, optionUserServiceDefault) {
+		return true
+	}
+	return kv.bool(optionPreferUserService, false) && !hasRootPrivileges()
+}
+
+// lookupOwner resolves a user and group name to a uid and gid. An empty name
+// resolves to -1, which os.Chown leaves unchanged.
+func lookupOwner(userName, groupName string) (uid, gid int, err error) {
+	uid, gid = -1, -1
+	if userName != "" {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			return -1, -1, err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return -1, -1, fmt.Errorf("user %q has non-numeric uid %q", userName, u.Uid)
+		}
+	}
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			return -1, -1, err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return -1, -1, fmt.Errorf("group %q has non-numeric gid %q", groupName, g.Gid)
+		}
+	}
+	return uid, gid, nil
+}
+
+// checkExecutable returns an error if path is not a regular file with an
+// executable bit set for userName. The bits of userName are only checked if
+// the user and its groups can be looked up, otherwise any executable bit will do.
+func checkExecutable(path, userName string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("executable: %v", err)
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("executable %s is not a regular file", path)
+	}
+	mode := fi.Mode().Perm()
+	if mode&0111 == 0 {
+		return fmt.Errorf("executable %s has no executable bit set, mode %v", path, mode)
+	}
+	if userName == "" {
+		return nil
+	}
+	u, err := user.Lookup(userName)
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if err != nil || !ok || u.Uid == "0" {
+		return nil
+	}
+	bit := os.FileMode(0001)
+	if fmt.Sprint(st.Uid) == u.Uid {
+		bit = 0100
+	} else if gids, err := u.GroupIds(); err != nil {
+		return nil
+	} else {
+		for _, gid := range gids {
+			if gid == fmt.Sprint(st.Gid) {
+				bit = 0010
+				break
+			}
+		}
+	}
+	if mode&bit == 0 {
+		return fmt.Errorf("executable %s can not be executed by user %s, mode %v", path, userName, mode)
+	}
+	return nil
+}
+
+// chownLogFiles creates each log file if it doesn't exist and changes its
+// owner to uid and gid.
+func chownLogFiles(uid, gid int, paths ...string) error {
+	for _, p := range paths {
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		f.Close()
+		if err := os.Chown(p, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syslogNew connects to the system log. Tests replace it.
+var syslogNew = syslog.New
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+// newSysLogger returns a Logger writing to the system log with the
+// SyslogFacility and SyslogTag of kv, tagged name by default.
+func newSysLogger(kv KeyValue, name string, errs chan<- error) (Logger, error) {
+	priority := syslog.LOG_INFO
+	if facility := kv.string(optionSyslogFacility, ""); facility != "" {
+		p, ok := syslogFacilities[strings.ToLower(facility)]
+		if !ok {
+			return nil, fmt.Errorf("invalid %s %q", optionSyslogFacility, facility)
+		}
+		priority |= p
+	}
+	w, err := syslogNew(priority, kv.string(optionSyslogTag, name))
 	if err != nil {
 		return nil, err
 	}
@@ -58,85 +193,83 @@ func (s sysLogger) Infof(format string, a ...interface{}) error {
 	return s.send(s.Writer.Info(fmt.Sprintf(format, a...)))
 }
 
-func run(command string, arguments ...string) error {
-	_, _, err := runCommand(command, false, arguments...)
-	return err
-}
-
-func runWithOutput(command string, arguments ...string) (int, string, error) {
-	return runCommand(command, true, arguments...)
-}
-
-func runCommand(command string, readStdout bool, arguments ...string) (int, string, error) {
-	cmd := exec.Command(command, arguments...)
-
-	var output string
-	var stdout io.ReadCloser
-	var err error
-
-	if readStdout {
-		// Connect pipe to read Stdout
-		stdout, err = cmd.StdoutPipe()
-
-		if err != nil {
-			// Failed to connect pipe
-			return 0, "", fmt.Errorf("%q failed to connect stdout pipe: %v", command, err)
-		}
-	}
-
-	// Connect pipe to read Stderr
-	stderr, err := cmd.StderrPipe()
-
-	if err != nil {
-		// Failed to connect pipe
-		return 0, "", fmt.Errorf("%q failed to connect stderr pipe: %v", command, err)
-	}
-
-	// Do not use cmd.Run()
-	if err := cmd.Start(); err != nil {
-		// Problem while copying stdin, stdout, or stderr
-		return 0, "", fmt.Errorf("%q failed: %v", command, err)
+// waitForStopSignal blocks until Run is asked to stop with SIGTERM or SIGINT.
+// If i is a Signaler or a Reloader SIGQUIT stops too and SIGHUP does not,
+// a Signaler is told of every signal and a Reloader reloads on SIGHUP.
+func waitForStopSignal(s Service, i Interface) {
+	var sigChan = make(chan os.Signal, 3)
+	signaler, isSignaler := i.(Signaler)
+	reloader, isReloader := i.(Reloader)
+	if !isSignaler && !isReloader {
+		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
+		<-sigChan
+		return
 	}
-
-	// Zero exit status
-	// Darwin: launchctl can fail with a zero exit status,
-	// so check for emtpy stderr
-	if command == "launchctl" {
-		slurp, _ := ioutil.ReadAll(stderr)
-		if len(slurp) > 0 && !bytes.HasSuffix(slurp, []byte("Operation now in progress\n")) {
-			return 0, "", fmt.Errorf("%q failed with stderr: %s", command, slurp)
+	// Reload errors go to the Logger, which may open a file or a connection,
+	// so it is only asked for once.
+	var logger Logger
+	if isReloader && s != nil {
+		if l, err := s.Logger(nil); err == nil {
+			logger = l
 		}
 	}
-
-	if readStdout {
-		out, err := ioutil.ReadAll(stdout)
-		if err != nil {
-			return 0, "", fmt.Errorf("%q failed while attempting to read stdout: %v", command, err)
-		} else if len(out) > 0 {
-			output = string(out)
+	signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt, syscall.SIGQUIT, syscall.SIGHUP)
+	for {
+		sig := <-sigChan
+		if isSignaler {
+			signaler.OnSignal(s, sig)
 		}
-	}
-
-	if err := cmd.Wait(); err != nil {
-		exitStatus, ok := isExitError(err)
-		if ok {
-			// Command didn't exit with a zero exit status.
-			return exitStatus, output, err
+		if sig != syscall.SIGHUP {
+			return
+		}
+		if isReloader {
+			if err := reloader.Reload(s); err != nil && logger != nil {
+				logger.Errorf("reload: %v", err)
+			}
 		}
-
-		// An error occurred and there is no exit status.
-		return 0, output, fmt.Errorf("%q failed: %v", command, err)
 	}
-
-	return 0, output, nil
 }
 
-func isExitError(err error) (int, bool) {
-	if exiterr, ok := err.(*exec.ExitError); ok {
-		if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
-			return status.ExitStatus(), true
+// reapChildren reaps terminated child processes on SIGCHLD until the
+// returned func is called.
+func reapChildren() (stop func()) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGCHLD)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-sigChan:
+			case <-done:
+				return
+			}
+			// Signals coalesce, reap every child that has exited.
+			for {
+				var status syscall.WaitStatus
+				pid, err := syscall.Wait4(-1, &status, wnohang, nil)
+				if pid <= 0 || err != nil {
+					break
+				}
+			}
 		}
+	}()
+	return func() {
+		signal.Stop(sigChan)
+		close(done)
 	}
+}
 
-	return 0, false
+// setArgv0 overwrites the original argv[0] memory in place so the new name
+// shows up in ps. The name is truncated to the length of the original argv[0].
+func setArgv0(name string) {
+	n := len(os.Args[0])
+	if n == 0 {
+		return
+	}
+	argv0 := (*[1 << 30]byte)(*(*unsafe.Pointer)(unsafe.Pointer(&os.Args[0])))[:n:n]
+	i := copy(argv0, name)
+	for ; i < n; i++ {
+		argv0[i] = 0
+	}
+	os.Args[0] = name
 }
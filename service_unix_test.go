@@ -0,0 +1,314 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build linux || darwin || solaris || aix || freebsd
+// +build linux darwin solaris aix freebsd
+
+package service
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log/syslog"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func Test_lookupOwner(t *testing.T) {
+	u, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantUID, _ := strconv.Atoi(u.Uid)
+
+	tests := []struct {
+		name      string
+		userName  string
+		groupName string
+		wantUID   int
+		wantGID   int
+		wantErr   bool
+	}{
+		{"empty", "", "", -1, -1, false},
+		{"current-user", u.Username, "", wantUID, -1, false},
+		{"missing-user", "no-such-user-svc", "", -1, -1, true},
+		{"missing-group", "", "no-such-group-svc", -1, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uid, gid, err := lookupOwner(tt.userName, tt.groupName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupOwner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if uid != tt.wantUID || gid != tt.wantGID {
+				t.Errorf("lookupOwner() = %d, %d, want %d, %d", uid, gid, tt.wantUID, tt.wantGID)
+			}
+		})
+	}
+}
+
+func Test_chownLogFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	uid, gid := os.Getuid(), os.Getgid()
+	paths := []string{filepath.Join(dir, "test.out.log"), filepath.Join(dir, "test.err.log")}
+	if err := chownLogFiles(uid, gid, paths...); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st := fi.Sys().(*syscall.Stat_t)
+		if int(st.Uid) != uid || int(st.Gid) != gid {
+			t.Errorf("%s owned by %d:%d, want %d:%d", p, st.Uid, st.Gid, uid, gid)
+		}
+	}
+}
+
+func Test_runCommandError(t *testing.T) {
+	_, out, err := runWithOutput("sh", "-c", "echo out; echo err >&2; exit 3")
+	cmdErr, ok := err.(*CommandError)
+	if !ok {
+		t.Fatalf("runWithOutput() error = %#v, want *CommandError", err)
+	}
+	if cmdErr.Command != "sh" || len(cmdErr.Args) != 2 {
+		t.Errorf("Command = %q %q, want sh with 2 arguments", cmdErr.Command, cmdErr.Args)
+	}
+	if cmdErr.ExitCode != 3 {
+		t.Errorf("ExitCode = %d, want 3", cmdErr.ExitCode)
+	}
+	if out != "out\n" || cmdErr.Stdout != "out\n" {
+		t.Errorf("Stdout = %q, output = %q, want %q", cmdErr.Stdout, out, "out\n")
+	}
+	if cmdErr.Stderr != "err\n" {
+		t.Errorf("Stderr = %q, want %q", cmdErr.Stderr, "err\n")
+	}
+	if !strings.Contains(cmdErr.Error(), "with stderr: err") {
+		t.Errorf("Error() = %q, want it to include stderr", cmdErr.Error())
+	}
+
+	err = run("sh", "-c", "echo err >&2")
+	if err != nil {
+		t.Errorf("run() error = %v, want nil", err)
+	}
+}
+
+func Test_runCommandContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := runWithOutputContext(ctx, "sh", "-c", "sleep 10 & wait")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("runWithOutputContext() returned after %v, want it canceled", elapsed)
+	}
+	cmdErr, ok := err.(*CommandError)
+	if !ok || cmdErr.Err != context.DeadlineExceeded {
+		t.Errorf("runWithOutputContext() error = %v, want a CommandError with %v", err, context.DeadlineExceeded)
+	}
+
+	if _, out, err := runWithOutputContext(context.Background(), "sh", "-c", "echo ok"); err != nil || out != "ok\n" {
+		t.Errorf("runWithOutputContext() = %q, %v, want %q", out, err, "ok\n")
+	}
+}
+
+func Test_checkHealth(t *testing.T) {
+	managerErr := errors.New("manager failed")
+	tests := []struct {
+		name    string
+		command string
+		status  Status
+		err     error
+		want    Status
+		wantErr bool
+	}{
+		{"no command", "", StatusRunning, nil, StatusRunning, false},
+		{"healthy", "exit 0", StatusRunning, nil, StatusRunning, false},
+		{"unhealthy", "exit 3", StatusRunning, nil, StatusDegraded, false},
+		{"stopped", "exit 3", StatusStopped, nil, StatusStopped, false},
+		{"manager error", "exit 0", StatusUnknown, managerErr, StatusUnknown, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kv := KeyValue{optionHealthCheckCommand: tt.command}
+			got, err := checkHealth(context.Background(), kv, tt.status, tt.err)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkHealth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("checkHealth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_reapChildren(t *testing.T) {
+	stop := reapChildren()
+	defer stop()
+
+	// Not os/exec, its Wait would race with the reaper.
+	pid, err := syscall.ForkExec("/bin/sh", []string{"sh", "-c", "exit 0"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reaped := waitUntil(5*time.Second, 10*time.Millisecond, func() bool {
+		// A zombie still accepts signal 0, a reaped process does not exist.
+		return syscall.Kill(pid, 0) == syscall.ESRCH
+	})
+	if !reaped {
+		t.Errorf("child %d was not reaped", pid)
+	}
+}
+
+type signalTestService struct {
+	signals chan os.Signal
+}
+
+func (p *signalTestService) Start(s Service) error { return nil }
+func (p *signalTestService) Stop(s Service) error  { return nil }
+
+func (p *signalTestService) OnSignal(s Service, sig os.Signal) {
+	p.signals <- sig
+}
+
+type reloadTestService struct {
+	reloads chan struct{}
+}
+
+func (p *reloadTestService) Start(s Service) error { return nil }
+func (p *reloadTestService) Stop(s Service) error  { return nil }
+
+func (p *reloadTestService) Reload(s Service) error {
+	p.reloads <- struct{}{}
+	return nil
+}
+
+func TestWaitForStopSignalReload(t *testing.T) {
+	guard := make(chan os.Signal, 10)
+	signal.Notify(guard, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(guard)
+
+	p := &reloadTestService{reloads: make(chan struct{}, 10)}
+	done := make(chan struct{})
+	go func() {
+		waitForStopSignal(nil, p)
+		close(done)
+	}()
+
+	// Signals sent before waitForStopSignal registered are lost, retry.
+	reloaded := false
+	for i := 0; i < 100 && !reloaded; i++ {
+		syscall.Kill(os.Getpid(), syscall.SIGHUP)
+		select {
+		case <-p.reloads:
+			reloaded = true
+		case <-done:
+			t.Fatal("waitForStopSignal() returned after SIGHUP")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	if !reloaded {
+		t.Fatal("Reload() was not called for SIGHUP")
+	}
+
+	syscall.Kill(os.Getpid(), syscall.SIGQUIT)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForStopSignal() did not return after SIGQUIT")
+	}
+}
+
+func TestWaitForStopSignal(t *testing.T) {
+	// Keep the default action of SIGHUP from ending the test before
+	// waitForStopSignal has registered.
+	guard := make(chan os.Signal, 10)
+	signal.Notify(guard, syscall.SIGHUP, syscall.SIGTERM)
+	defer signal.Stop(guard)
+
+	p := &signalTestService{signals: make(chan os.Signal, 10)}
+	done := make(chan struct{})
+	go func() {
+		waitForStopSignal(nil, p)
+		close(done)
+	}()
+
+	// Signals sent before waitForStopSignal registered are lost, retry.
+	send := func(sig syscall.Signal) {
+		t.Helper()
+		for i := 0; i < 100; i++ {
+			syscall.Kill(os.Getpid(), sig)
+			select {
+			case got := <-p.signals:
+				if got != sig {
+					t.Fatalf("OnSignal() got %v, want %v", got, sig)
+				}
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+		t.Fatalf("OnSignal() was not called for %v", sig)
+	}
+
+	send(syscall.SIGHUP)
+	select {
+	case <-done:
+		t.Fatal("waitForStopSignal() returned after SIGHUP")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	send(syscall.SIGTERM)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("waitForStopSignal() did not return after SIGTERM")
+	}
+}
+
+func TestSysLoggerFacilityAndTag(t *testing.T) {
+	defer func(n func(syslog.Priority, string) (*syslog.Writer, error)) { syslogNew = n }(syslogNew)
+	var gotPriority syslog.Priority
+	var gotTag string
+	errFake := errors.New("fake syslog")
+	syslogNew = func(priority syslog.Priority, tag string) (*syslog.Writer, error) {
+		gotPriority, gotTag = priority, tag
+		return nil, errFake
+	}
+
+	tests := []struct {
+		kv       KeyValue
+		priority syslog.Priority
+		tag      string
+	}{
+		{nil, syslog.LOG_INFO, "app"},
+		{KeyValue{optionSyslogFacility: "local3", optionSyslogTag: "app-worker"}, syslog.LOG_INFO | syslog.LOG_LOCAL3, "app-worker"},
+		{KeyValue{optionSyslogFacility: "DAEMON"}, syslog.LOG_INFO | syslog.LOG_DAEMON, "app"},
+	}
+	for _, tt := range tests {
+		if _, err := newSysLogger(tt.kv, "app", nil); err != errFake {
+			t.Fatalf("%v: newSysLogger() = %v, want the syslog error", tt.kv, err)
+		}
+		if gotPriority != tt.priority || gotTag != tt.tag {
+			t.Errorf("%v: syslog.New(%v, %q), want (%v, %q)", tt.kv, gotPriority, gotTag, tt.priority, tt.tag)
+		}
+	}
+
+	if _, err := newSysLogger(KeyValue{optionSyslogFacility: "local8"}, "app", nil); err == nil || err == errFake {
+		t.Errorf("newSysLogger() with facility local8 = %v, want an invalid facility error", err)
+	}
+}
@@ -5,13 +5,13 @@
 package service
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
-	"os/signal"
 	"regexp"
 	"strings"
-	"syscall"
 	"text/template"
 )
 
@@ -56,17 +56,25 @@ func (s *upstart) Platform() string {
 	return s.platform
 }
 
+// Capabilities reports reload support, initctl reload sends SIGHUP to the job.
+func (s *upstart) Capabilities() Capability {
+	return CapabilityReload
+}
+
 // Upstart has some support for user services in graphical sessions.
 // Due to the mix of actual support for user services over versions, just don't bother.
 // Upstart will be replaced by systemd in most cases anyway.
-var errNoUserServiceUpstart = errors.New("User services are not supported on Upstart.")
+var errNoUserServiceUpstart = notSupported("user service", "Upstart")
+
+// upstartConfigDir holds the job files. Tests replace it.
+var upstartConfigDir = "/etc/init"
 
 func (s *upstart) configPath() (cp string, err error) {
 	if s.Option.bool(optionUserService, optionUserServiceDefault) {
 		err = errNoUserServiceUpstart
 		return
 	}
-	cp = "/etc/init/" + s.Config.Name + ".conf"
+	cp = upstartConfigDir + "/" + s.Config.Name + ".conf"
 	return
 }
 
@@ -126,6 +134,10 @@ func (s *upstart) template() *template.Template {
 }
 
 func (s *upstart) Install() error {
+	if _, err := restartPolicy(s.Option); err != nil {
+		return err
+	}
+
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
@@ -141,6 +153,20 @@ func (s *upstart) Install() error {
 	}
 	defer f.Close()
 
+	if err = f.Chmod(fileMode(s.Option, 0644)); err != nil {
+		return err
+	}
+
+	return s.render(f)
+}
+
+// render writes the job configuration to w.
+func (s *upstart) render(w io.Writer) error {
+	policy, err := restartPolicy(s.Option)
+	if err != nil {
+		return err
+	}
+
 	path, err := s.execPath()
 	if err != nil {
 		return err
@@ -148,21 +174,27 @@ func (s *upstart) Install() error {
 
 	var to = &struct {
 		*Config
-		Path            string
-		HasKillStanza   bool
-		HasSetUIDStanza bool
-		LogOutput       bool
-		LogDirectory    string
+		Path               string
+		HasKillStanza      bool
+		HasSetUIDStanza    bool
+		Respawn            bool
+		RespawnOnFailure   bool
+		LogOutput          bool
+		LogDirectory       string
+		AfterNetworkOnline bool
 	}{
 		s.Config,
 		path,
 		s.hasKillStanza(),
 		s.hasSetUIDStanza(),
+		policy != restartPolicyNo,
+		policy == restartPolicyOnFailure,
 		s.Option.bool(optionLogOutput, optionLogOutputDefault),
 		s.Option.string(optionLogDirectory, defaultLogDirectory),
+		s.Option.bool(optionAfterNetworkOnline, optionAfterNetworkOnlineDefault),
 	}
 
-	return s.template().Execute(f, to)
+	return s.template().Execute(w, to)
 }
 
 func (s *upstart) Uninstall() error {
@@ -183,25 +215,38 @@ func (s *upstart) Logger(errs chan<- error) (Logger, error) {
 	return s.SystemLogger(errs)
 }
 func (s *upstart) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Option, s.Name, errs)
 }
 
 func (s *upstart) Run() (err error) {
-	err = s.i.Start(s)
+	if name := s.Option.string(optionProcessName, ""); name != "" {
+		if err = setProcessName(name); err != nil {
+			return err
+		}
+	}
+
+	if s.Option.bool(optionReapChildren, optionReapChildrenDefault) {
+		defer reapChildren()()
+	}
+
+	err = callInterface(s, s.Option, s.i.Start)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		waitForStopSignal(s, s.i)
 	})()
 
-	return s.i.Stop(s)
+	return callInterface(s, s.Option, s.i.Stop)
 }
 
 func (s *upstart) Status() (Status, error) {
+	status, err := s.status()
+	return checkHealth(context.Background(), s.Option, status, err)
+}
+
+func (s *upstart) status() (Status, error) {
 	exitCode, out, err := runWithOutput("initctl", "status", s.Name)
 	if exitCode == 0 && err != nil {
 		return StatusUnknown, err
@@ -225,6 +270,20 @@ func (s *upstart) Stop() error {
 	return run("initctl", "stop", s.Name)
 }
 
+// DisableAndStop writes a manual override so the job no longer starts on
+// its start on events, then stops it.
+func (s *upstart) DisableAndStop() error {
+	cp, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	override := strings.TrimSuffix(cp, ".conf") + ".override"
+	if err := ioutil.WriteFile(override, []byte("manual\n"), 0644); err != nil {
+		return err
+	}
+	return s.Stop()
+}
+
 func (s *upstart) Restart() error {
 	return run("initctl", "restart", s.Name)
 }
@@ -238,14 +297,15 @@ const upstartScript = `# {{.Description}}
 {{if .HasKillStanza}}kill signal INT{{end}}
 {{if .ChRoot}}chroot {{.ChRoot}}{{end}}
 {{if .WorkingDirectory}}chdir {{.WorkingDirectory}}{{end}}
-start on filesystem or runlevel [2345]
+{{if .AfterNetworkOnline}}start on (local-filesystems and net-device-up IFACE!=lo){{else}}start on filesystem or runlevel [2345]{{end}}
 stop on runlevel [!2345]
 
 {{if and .UserName .HasSetUIDStanza}}setuid {{.UserName}}{{end}}
 
-respawn
+{{if .Respawn}}respawn
 respawn limit 10 5
-umask 022
+{{if .RespawnOnFailure}}normal exit 0
+{{end}}{{end}}umask 022
 
 console none
 
@@ -0,0 +1,74 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUpstartRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy         string
+		wantRespawn    bool
+		wantNormalExit bool
+	}{
+		{"", true, false},
+		{restartPolicyAlways, true, false},
+		{restartPolicyOnFailure, true, true},
+		{restartPolicyNo, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			s := &upstart{Config: &Config{
+				Name:       "test",
+				Executable: "/usr/bin/test",
+				Option:     KeyValue{optionRestartPolicy: tt.policy},
+			}}
+			var b bytes.Buffer
+			if err := s.render(&b); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(b.String(), "\nrespawn\n"); got != tt.wantRespawn {
+				t.Errorf("respawn stanza present = %v, want %v", got, tt.wantRespawn)
+			}
+			if got := strings.Contains(b.String(), "normal exit 0"); got != tt.wantNormalExit {
+				t.Errorf("normal exit stanza present = %v, want %v", got, tt.wantNormalExit)
+			}
+		})
+	}
+
+	s := &upstart{Config: &Config{Name: "test", Option: KeyValue{optionRestartPolicy: "sometimes"}}}
+	if err := s.render(&bytes.Buffer{}); err == nil {
+		t.Error("render() with invalid RestartPolicy succeeded, want error")
+	}
+}
+
+func TestUpstartDisableAndStop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "servicetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(d string) { upstartConfigDir = d }(upstartConfigDir)
+	upstartConfigDir = dir
+
+	calls, restore := fakeCommandRunner(map[string]fakeResult{"initctl stop test": {}})
+	defer restore()
+	s := &upstart{Config: &Config{Name: "test"}}
+	if err := s.DisableAndStop(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "test.override")); err != nil || string(b) != "manual\n" {
+		t.Errorf("test.override = %q, %v, want manual", b, err)
+	}
+	if got, want := strings.Join(*calls, ","), "initctl stop test"; got != want {
+		t.Errorf("DisableAndStop() ran %q, want %q", got, want)
+	}
+}
@@ -5,6 +5,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -33,7 +34,7 @@ const (
 	OnFailureRestart       = "restart"
 	OnFailureReboot        = "reboot"
 	OnFailureNoAction      = "noaction"
-	OnFailureDelayDuration = "OnFailureDelayDuration"
+	OnFailureDelayDuration = optionOnFailureDelayDuration
 	OnFailureResetPeriod   = "OnFailureResetPeriod"
 
 	errnoServiceDoesNotExist syscall.Errno = 1060
@@ -167,6 +168,93 @@ func (ws *windowsService) Platform() string {
 	return version
 }
 
+// Properties returns the service configuration printed by sc qc and the
+// failure actions printed by sc qfailure, keyed by their upper case names.
+func (ws *windowsService) Properties() (map[string]string, error) {
+	props := make(map[string]string)
+	for _, query := range []string{"qc", "qfailure"} {
+		_, out, err := runWithOutput("sc", query, ws.Name)
+		if err != nil {
+			return nil, err
+		}
+		parseSCProperties(out, props)
+	}
+	return props, nil
+}
+
+// parseSCProperties adds the "KEY : value" lines of sc output to props. A
+// value continued on the next lines is joined with newlines.
+func parseSCProperties(out string, props map[string]string) {
+	var last string
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "[SC]") {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) == 2 && strings.TrimSpace(kv[0]) != "" {
+			last = strings.TrimSpace(kv[0])
+			props[last] = strings.TrimSpace(kv[1])
+			continue
+		}
+		if last == "" {
+			continue
+		}
+		if len(kv) == 2 {
+			line = strings.TrimSpace(kv[1])
+		}
+		if props[last] == "" {
+			props[last] = line
+		} else {
+			props[last] += "\n" + line
+		}
+	}
+}
+
+// LastRunResult reads LastTaskResult of the scheduled task with the service name.
+// Task Scheduler does not record when a run finished, FinishedAt is left zero.
+func (ws *windowsService) LastRunResult() (*RunResult, error) {
+	script := fmt.Sprintf("(Get-ScheduledTaskInfo -TaskName '%s').LastTaskResult", strings.Replace(ws.Name, "'", "''", -1))
+	_, out, err := runWithOutput("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	if err != nil {
+		return nil, err
+	}
+	return parseLastTaskResult(out)
+}
+
+// RunNow starts the scheduled task with the service name immediately.
+func (ws *windowsService) RunNow() error {
+	if err := run("schtasks", "/query", "/tn", ws.Name); err != nil {
+		if cmdErr, ok := err.(*CommandError); ok && cmdErr.ExitCode != 0 {
+			return ErrNotSupported
+		}
+		return err
+	}
+	return run("schtasks", ws.runNowArgs()...)
+}
+
+func (ws *windowsService) runNowArgs() []string {
+	return []string{"/run", "/tn", ws.Name}
+}
+
+const (
+	schedTaskRunning   = 0x41301 // SCHED_S_TASK_RUNNING
+	schedTaskHasNotRun = 0x41303 // SCHED_S_TASK_HAS_NOT_RUN
+)
+
+// parseLastTaskResult parses the LastTaskResult value of a scheduled task.
+func parseLastTaskResult(out string) (*RunResult, error) {
+	v, err := strconv.ParseUint(strings.TrimSpace(out), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LastTaskResult %q: %v", strings.TrimSpace(out), err)
+	}
+	switch v {
+	case schedTaskRunning, schedTaskHasNotRun:
+		return &RunResult{}, nil
+	}
+	return &RunResult{ExitCode: int(v), Success: v == 0}, nil
+}
+
 func (ws *windowsService) setError(err error) {
 	ws.errSync.Lock()
 	defer ws.errSync.Unlock()
@@ -182,7 +270,7 @@ func (ws *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, cha
 	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown
 	changes <- svc.Status{State: svc.StartPending}
 
-	if err := ws.i.Start(ws); err != nil {
+	if err := callInterface(ws, ws.Option, ws.i.Start); err != nil {
 		ws.setError(err)
 		return true, 1
 	}
@@ -196,7 +284,7 @@ loop:
 			changes <- c.CurrentStatus
 		case svc.Stop:
 			changes <- svc.Status{State: svc.StopPending}
-			if err := ws.i.Stop(ws); err != nil {
+			if err := callInterface(ws, ws.Option, ws.i.Stop); err != nil {
 				ws.setError(err)
 				return true, 2
 			}
@@ -205,9 +293,9 @@ loop:
 			changes <- svc.Status{State: svc.StopPending}
 			var err error
 			if wsShutdown, ok := ws.i.(Shutdowner); ok {
-				err = wsShutdown.Shutdown(ws)
+				err = callInterface(ws, ws.Option, wsShutdown.Shutdown)
 			} else {
-				err = ws.i.Stop(ws)
+				err = callInterface(ws, ws.Option, ws.i.Stop)
 			}
 			if err != nil {
 				ws.setError(err)
@@ -265,7 +353,26 @@ func (ws *windowsService) setEnvironmentVariablesInRegistry() error {
 	return nil
 }
 
+// onFailure returns the recovery action, falling back to RestartPolicy when
+// OnFailure is not set.
+func (ws *windowsService) onFailure() string {
+	if onFailure := ws.Option.string(OnFailure, ""); onFailure != "" {
+		return onFailure
+	}
+	switch ws.Option.string(optionRestartPolicy, "") {
+	case restartPolicyNo:
+		return OnFailureNoAction
+	case restartPolicyOnFailure, restartPolicyAlways:
+		return OnFailureRestart
+	}
+	return ""
+}
+
 func (ws *windowsService) Install() error {
+	if _, err := restartPolicy(ws.Option); err != nil {
+		return err
+	}
+
 	exepath, err := ws.execPath()
 	if err != nil {
 		return err
@@ -314,11 +421,8 @@ func (ws *windowsService) Install() error {
 	if err != nil {
 		return err
 	}
-	if onFailure := ws.Option.string(OnFailure, ""); onFailure != "" {
-		var delay = 1 * time.Second
-		if d, err := time.ParseDuration(ws.Option.string(OnFailureDelayDuration, "1s")); err == nil {
-			delay = d
-		}
+	if onFailure := ws.onFailure(); onFailure != "" {
+		delay := ws.Option.duration(OnFailureDelayDuration, 1*time.Second)
 		var actionType int
 		switch onFailure {
 		case OnFailureReboot:
@@ -389,7 +493,7 @@ func (ws *windowsService) Run() error {
 		}
 		return nil
 	}
-	err := ws.i.Start(ws)
+	err := callInterface(ws, ws.Option, ws.i.Start)
 	if err != nil {
 		return err
 	}
@@ -398,12 +502,20 @@ func (ws *windowsService) Run() error {
 
 	signal.Notify(sigChan, os.Interrupt)
 
-	<-sigChan
+	sig := <-sigChan
+	if signaler, ok := ws.i.(Signaler); ok {
+		signaler.OnSignal(ws, sig)
+	}
 
-	return ws.i.Stop(ws)
+	return callInterface(ws, ws.Option, ws.i.Stop)
 }
 
 func (ws *windowsService) Status() (Status, error) {
+	status, err := ws.status()
+	return checkHealth(context.Background(), ws.Option, status, err)
+}
+
+func (ws *windowsService) status() (Status, error) {
 	m, err := lowPrivMgr()
 	if err != nil {
 		return StatusUnknown, err
@@ -475,6 +587,31 @@ func (ws *windowsService) Stop() error {
 	return ws.stopWait(s)
 }
 
+// DisableAndStop sets the start type to disabled and stops the service.
+func (ws *windowsService) DisableAndStop() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(ws.Name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", ws.Name)
+	}
+	defer s.Close()
+
+	c, err := s.Config()
+	if err != nil {
+		return err
+	}
+	c.StartType = mgr.StartDisabled
+	if err = s.UpdateConfig(c); err != nil {
+		return err
+	}
+	return ws.stopWait(s)
+}
+
 func (ws *windowsService) Restart() error {
 	m, err := lowPrivMgr()
 	if err != nil {
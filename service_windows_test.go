@@ -5,6 +5,7 @@
 package service
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -12,3 +13,92 @@ func TestTimeout(t *testing.T) {
 	stopSpan := getStopTimeout()
 	t.Log("Max Stop Duration", stopSpan)
 }
+
+func TestOnFailureRestartPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		option KeyValue
+		want   string
+	}{
+		{"unset", nil, ""},
+		{"no", KeyValue{optionRestartPolicy: restartPolicyNo}, OnFailureNoAction},
+		{"on-failure", KeyValue{optionRestartPolicy: restartPolicyOnFailure}, OnFailureRestart},
+		{"always", KeyValue{optionRestartPolicy: restartPolicyAlways}, OnFailureRestart},
+		{"onfailure-overrides", KeyValue{optionRestartPolicy: restartPolicyNo, OnFailure: OnFailureReboot}, OnFailureReboot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &windowsService{Config: &Config{Name: "test", Option: tt.option}}
+			if got := ws.onFailure(); got != tt.want {
+				t.Errorf("onFailure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLastTaskResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    RunResult
+		wantErr bool
+	}{
+		{"success", "0\r\n", RunResult{ExitCode: 0, Success: true}, false},
+		{"failure", "1\r\n", RunResult{ExitCode: 1}, false},
+		{"has not run", "267011\r\n", RunResult{}, false},
+		{"running", "267009\r\n", RunResult{}, false},
+		{"invalid", "\r\n", RunResult{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLastTaskResult(tt.out)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLastTaskResult() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t.Errorf("parseLastTaskResult() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunNowArgs(t *testing.T) {
+	ws := &windowsService{Config: &Config{Name: "test"}}
+	if got, want := strings.Join(ws.runNowArgs(), " "), "/run /tn test"; got != want {
+		t.Errorf("runNowArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestParseSCProperties(t *testing.T) {
+	const qc = `[SC] QueryServiceConfig SUCCESS
+
+SERVICE_NAME: test
+        TYPE               : 10  WIN32_OWN_PROCESS
+        START_TYPE         : 2   AUTO_START
+        BINARY_PATH_NAME   : C:\test\test.exe -v
+        DEPENDENCIES       : Tcpip
+                           : Dnscache
+`
+	const qfailure = `[SC] QueryServiceConfig2 SUCCESS
+
+SERVICE_NAME: test
+        RESET_PERIOD (in seconds)    : 86400
+        FAILURE_ACTIONS              : RESTART -- Delay = 60000 milliseconds.
+                                       RESTART -- Delay = 120000 milliseconds.
+`
+	got := make(map[string]string)
+	parseSCProperties(qc, got)
+	parseSCProperties(qfailure, got)
+	for k, v := range map[string]string{
+		"SERVICE_NAME":              "test",
+		"START_TYPE":                "2   AUTO_START",
+		"BINARY_PATH_NAME":          `C:\test\test.exe -v`,
+		"DEPENDENCIES":              "Tcpip\nDnscache",
+		"RESET_PERIOD (in seconds)": "86400",
+		"FAILURE_ACTIONS":           "RESTART -- Delay = 60000 milliseconds.\nRESTART -- Delay = 120000 milliseconds.",
+	} {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build linux || darwin || solaris || freebsd || openbsd
+// +build linux darwin solaris freebsd openbsd
+
+package service
+
+import "syscall"
+
+const wnohang = syscall.WNOHANG
@@ -0,0 +1,26 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import "time"
+
+// waitUntil calls ready every interval until it returns true or the timeout
+// elapses. It reports whether ready returned true.
+func waitUntil(timeout, interval time.Duration, ready func() bool) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if ready() {
+			return true
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+		if remaining < interval {
+			interval = remaining
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_waitUntil(t *testing.T) {
+	tests := []struct {
+		name      string
+		readyAt   int
+		timeout   time.Duration
+		want      bool
+		wantCalls int
+	}{
+		{"immediate", 1, 50 * time.Millisecond, true, 1},
+		{"after-polls", 3, time.Second, true, 3},
+		{"timeout", -1, 30 * time.Millisecond, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			start := time.Now()
+			got := waitUntil(tt.timeout, 5*time.Millisecond, func() bool {
+				calls++
+				return calls == tt.readyAt
+			})
+			if got != tt.want {
+				t.Errorf("waitUntil() = %v, want %v", got, tt.want)
+			}
+			if tt.wantCalls > 0 && calls != tt.wantCalls {
+				t.Errorf("ready called %d times, want %d", calls, tt.wantCalls)
+			}
+			if !tt.want && time.Since(start) < tt.timeout {
+				t.Errorf("waitUntil() returned after %v, before timeout %v", time.Since(start), tt.timeout)
+			}
+		})
+	}
+}